@@ -0,0 +1,126 @@
+package main
+
+// VPS automated backups (daily, optional).
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"log"
+	"net/url"
+	"time"
+)
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/automatedBackup/restorePoints~GET
+type GetVPSNameAutomatedBackupRestorePoints []string
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/automatedBackup/restore~POST
+type PostInVPSNameAutomatedBackupRestore struct {
+	ChangePassword bool   `json:"changePassword"`
+	RestorePoint   string `json:"restorePoint"`
+	Type           string `json:"type"`
+}
+type PostOutVPSNameAutomatedBackupRestore VPSTask
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/automatedBackup/attachedBackup~GET
+type VPSAttachedBackupAccess struct {
+	AdditionalDisk string `json:"additionalDisk"`
+	Nfs            string `json:"nfs"`
+	Smb            string `json:"smb"`
+}
+type GetVPSNameAutomatedBackupAttachedBackup []struct {
+	Access       VPSAttachedBackupAccess `json:"access"`
+	RestorePoint time.Time               `json:"restorePoint"`
+}
+
+// ls-backups' items
+type VPSBackup struct {
+	RestorePoint string `json:"restorePoint"`
+}
+
+// v's available restore points, most recent first
+func getRestorePoints(c *ovh.Client, v string) ([]string, error) {
+	var xs GetVPSNameAutomatedBackupRestorePoints
+	q := url.Values{"state": {"available"}}
+	if err := c.Get("/vps/"+v+"/automatedBackup/restorePoints?"+q.Encode(), &xs); err != nil {
+		return nil, err
+	}
+	sortBackupDates(xs)
+	return xs, nil
+}
+
+func lsBackups(c *ovh.Client, v string) error {
+	xs, err := getRestorePoints(c, v)
+	if err != nil {
+		return err
+	}
+
+	l := newLister(func(y VPSBackup) {
+		fmt.Println(y.RestorePoint)
+	})
+	for _, x := range xs {
+		if err := l.add(VPSBackup{x}); err != nil {
+			return err
+		}
+	}
+	return l.done()
+}
+
+// print how to access v's restore point d, once attached
+func printBackupAccess(c *ovh.Client, v, d string) error {
+	var xs GetVPSNameAutomatedBackupAttachedBackup
+	if err := c.Get("/vps/"+v+"/automatedBackup/attachedBackup", &xs); err != nil {
+		return err
+	}
+
+	t, err := time.Parse(time.RFC3339, d)
+	if err != nil {
+		return err
+	}
+	for _, x := range xs {
+		if !x.RestorePoint.Equal(t) {
+			continue
+		}
+		fmt.Printf("restorePoint:   %s\n", d)
+		fmt.Printf("nfs:            %s\n", x.Access.Nfs)
+		fmt.Printf("smb:            %s\n", x.Access.Smb)
+		fmt.Printf("additionalDisk: %s\n", x.Access.AdditionalDisk)
+		return nil
+	}
+
+	return fmt.Errorf("Backup %s not attached", d)
+}
+
+// restore v to the backup selected by s (see pickBackup()):
+// fully (m is "full"), or by attaching it (m is "file"), in
+// which case the access details are printed.
+func restoreBackup(c *ovh.Client, v, s, m string) error {
+	if m != "full" && m != "file" {
+		return fmt.Errorf("Invalid restore mode '%s' (full, file)", m)
+	}
+
+	xs, err := getRestorePoints(c, v)
+	if err != nil {
+		return err
+	}
+	d, err := pickBackup(xs, s)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Restoring %s to %s (%s)\n", v, d, m)
+
+	x := PostInVPSNameAutomatedBackupRestore{false, d, m}
+	var y PostOutVPSNameAutomatedBackupRestore
+	if err := c.Post("/vps/"+v+"/automatedBackup/restore", &x, &y); err != nil {
+		return err
+	}
+	if err := poolTask(c, v, y.Id); err != nil {
+		return err
+	}
+
+	if m == "file" {
+		return printBackupAccess(c, v, d)
+	}
+	fmt.Printf("%s restored to %s\n", v, d)
+	return nil
+}
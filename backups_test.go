@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRestoreBackup(t *testing.T) {
+	doTests(t, []test{
+		{
+			"invalid mode",
+			restoreBackup,
+			[]interface{}{(*ovh.Client)(nil), "vps-1.vps.ovh.net", "latest", "fast"},
+			[]interface{}{fmt.Errorf("Invalid restore mode 'fast' (full, file)")},
+		},
+	})
+}
+
+func TestPrintBackupAccess(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/time":
+			fmt.Fprint(w, time.Now().Unix())
+		case "/vps/v/automatedBackup/attachedBackup":
+			fmt.Fprint(w, `[{"restorePoint": "2023-02-01T09:00:00Z",
+				"access": {"nfs": "10.0.0.1:/backup"}}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	c, err := ovh.NewClient(s.URL, "ak", "as", "ck")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doTests(t, []test{
+		{
+			"another timezone",
+			printBackupAccess,
+			[]interface{}{c, "v", "2023-02-01T10:00:00+01:00"},
+			[]interface{}{nil},
+		},
+		{
+			"not attached",
+			printBackupAccess,
+			[]interface{}{c, "v", "2023-01-01T10:00:00+01:00"},
+			[]interface{}{fmt.Errorf("Backup 2023-01-01T10:00:00+01:00 not attached")},
+		},
+	})
+}
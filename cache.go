@@ -0,0 +1,160 @@
+package main
+
+// On-disk cache for slow-changing API resources, under
+// $XDG_CACHE_HOME/ovh-do, one directory per account.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// don't read the cache (see -no-cache); it's still updated
+var noCache = false
+
+// cached resources, by name: TTL, and matching API paths.
+// TTLs can be set with [ovh-do] cache.<name> keys.
+var cacheTTLs = map[string]time.Duration{
+	"images":      24 * time.Hour,
+	"datacenters": 24 * time.Hour,
+	"ips":         time.Hour,
+	"zones":       time.Hour,
+}
+
+var cachePaths = map[string][]string{
+	"images":      {"/vps/*/images/available", "/vps/*/images/available/*"},
+	"datacenters": {"/vps/*/datacenter"},
+	"ips":         {"/vps/*/ips"},
+	"zones":       {"/domain/zone", "/domain/zone/*"},
+}
+
+func cacheNames() []string {
+	var xs []string
+	for n := range cacheTTLs {
+		xs = append(xs, n)
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+func setCacheTTL(n string, d time.Duration) error {
+	if _, ok := cacheTTLs[n]; !ok {
+		return fmt.Errorf("Unknown cached resource '%s' (%s)", n, strings.Join(cacheNames(), ", "))
+	}
+	cacheTTLs[n] = d
+	return nil
+}
+
+// $XDG_CACHE_HOME/ovh-do
+func cacheDir() string {
+	d := os.Getenv("XDG_CACHE_HOME")
+	if d == "" {
+		d = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(d, "ovh-do")
+}
+
+// c's account cache directory. An application's consumer
+// keys may be validated by different accounts: a consumer
+// key is bound to one account, and the application key to
+// one endpoint (go-ovh doesn't expose c's).
+func accountCacheDir(c *ovh.Client) string {
+	h := sha256.Sum256([]byte(c.AppKey + "\n" + c.ConsumerKey))
+	return filepath.Join(cacheDir(), fmt.Sprintf("%x", h)[:16])
+}
+
+// TTL of the API path p; 0 if p isn't cached.
+func cacheTTL(p string) time.Duration {
+	for n, ps := range cachePaths {
+		for _, x := range ps {
+			if ok, _ := path.Match(x, p); ok {
+				return cacheTTLs[n]
+			}
+		}
+	}
+	return 0
+}
+
+func cacheFn(c *ovh.Client, p string) string {
+	return filepath.Join(accountCacheDir(c), url.PathEscape(p)+".json")
+}
+
+// read fn into y if it's fresh enough
+func readCache(fn string, ttl time.Duration, y interface{}) bool {
+	if noCache || ttl <= 0 {
+		return false
+	}
+	st, err := os.Stat(fn)
+	if err != nil || time.Since(st.ModTime()) > ttl {
+		return false
+	}
+	s, err := os.ReadFile(fn)
+	// a corrupted entry is just a miss
+	return err == nil && json.Unmarshal(s, y) == nil
+}
+
+// atomically write y as fn
+func writeCache(fn string, y interface{}) error {
+	s, err := json.Marshal(y)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(fn), ".tmp.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fn)
+}
+
+// c.GetWithContext(), going through the cache for cached paths
+func cachedGet(ctx context.Context, c *ovh.Client, p string, y interface{}) error {
+	ttl := cacheTTL(p)
+	if ttl == 0 {
+		return c.GetWithContext(ctx, p, y)
+	}
+
+	fn := cacheFn(c, p)
+	if readCache(fn, ttl, y) {
+		return nil
+	}
+
+	var x json.RawMessage
+	if err := c.GetWithContext(ctx, p, &x); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(x, y); err != nil {
+		return err
+	}
+	// a read-only cache directory shouldn't prevent anything
+	writeCache(fn, x)
+	return nil
+}
+
+// drop p's cached value, e.g. once modified
+func uncache(c *ovh.Client, p string) {
+	os.Remove(cacheFn(c, p))
+}
+
+func clearCache() error {
+	return os.RemoveAll(cacheDir())
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	doTests(t, []test{
+		{
+			"image",
+			cacheTTL,
+			[]interface{}{"/vps/vps-1.vps.ovh.net/images/available/42"},
+			[]interface{}{cacheTTLs["images"]},
+		},
+		{
+			"zone",
+			cacheTTL,
+			[]interface{}{"/domain/zone/example.com"},
+			[]interface{}{cacheTTLs["zones"]},
+		},
+		{
+			"zone records",
+			cacheTTL,
+			[]interface{}{"/domain/zone/example.com/record/42"},
+			[]interface{}{time.Duration(0)},
+		},
+		{
+			"VPS",
+			cacheTTL,
+			[]interface{}{"/vps/vps-1.vps.ovh.net"},
+			[]interface{}{time.Duration(0)},
+		},
+	})
+}
+
+func TestAccountCacheDir(t *testing.T) {
+	// same application, different accounts
+	a, err := ovh.NewClient("ovh-eu", "ak", "as", "ck1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ovh.NewClient("ovh-eu", "ak", "as", "ck2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountCacheDir(a) == accountCacheDir(b) {
+		t.Errorf("Consumer keys ck1 and ck2 share %s", accountCacheDir(a))
+	}
+}
+
+func TestCachedGet(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	n := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/time":
+			fmt.Fprint(w, time.Now().Unix())
+		default:
+			n++
+			fmt.Fprintf(w, `{"name": "gra%d"}`, n)
+		}
+	}))
+	defer s.Close()
+
+	c, err := ovh.NewClient(s.URL, "ak", "as", "ck")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// datacenter's name, and number of API calls so far
+	f := func(p string, nc bool) (string, int, error) {
+		var x GetVPSNameDatacenter
+		noCache = nc
+		defer func() { noCache = false }()
+		err := cachedGet(context.Background(), c, p, &x)
+		return x.Name, n, err
+	}
+
+	doTests(t, []test{
+		{
+			"miss",
+			f,
+			[]interface{}{"/vps/v/datacenter", false},
+			[]interface{}{"gra1", 1, nil},
+		},
+		{
+			"hit",
+			f,
+			[]interface{}{"/vps/v/datacenter", false},
+			[]interface{}{"gra1", 1, nil},
+		},
+		{
+			"no cache",
+			f,
+			[]interface{}{"/vps/v/datacenter", true},
+			[]interface{}{"gra2", 2, nil},
+		},
+		{
+			"updated anyway",
+			f,
+			[]interface{}{"/vps/v/datacenter", false},
+			[]interface{}{"gra2", 2, nil},
+		},
+		{
+			"not cached",
+			f,
+			[]interface{}{"/vps/v", false},
+			[]interface{}{"gra3", 3, nil},
+		},
+	})
+
+	if err := clearCache(); err != nil {
+		t.Fatal(err)
+	}
+	doTests(t, []test{
+		{
+			"cleared",
+			f,
+			[]interface{}{"/vps/v/datacenter", false},
+			[]interface{}{"gra4", 4, nil},
+		},
+	})
+}
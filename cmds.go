@@ -0,0 +1,846 @@
+package main
+
+// Commands declarations, arguments parsing and usage
+// generation.
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// run a command with its positional arguments
+type runner func(c *ovh.Client, as []string) error
+
+// how a command's client is obtained
+const (
+	// validated client (default), see getClient()
+	needClient = iota
+
+	// client whose consumer key may not be validated
+	rawClient
+
+	// no client; the command runs offline, or creates
+	// its own clients (e.g. profiles)
+	noClient
+)
+
+type cmd struct {
+	name string
+
+	// needClient, rawClient or noClient
+	client int
+
+	// positional arguments synopsis, e.g. "<vps> [key-name]"
+	args string
+
+	// the first positional argument designates a VPS,
+	// possibly by IP or DNS name (see resolveVPS())
+	vps bool
+
+	descr string
+
+	// number of positional arguments; max < 0 for no limit
+	min, max int
+
+	// register the command's flags on fs, and return
+	// the function running the command.
+	setup func(fs *flag.FlagSet) runner
+}
+
+// for commands without flags
+func noFlags(f runner) func(*flag.FlagSet) runner {
+	return func(*flag.FlagSet) runner { return f }
+}
+
+var cmds []*cmd
+
+func init() {
+	cmds = []*cmd{
+		{
+			name:   "help",
+			args:   "[command]",
+			descr:  "print the general usage, or a command's usage",
+			max:    2,
+			client: noClient,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				help(0, as)
+				return nil
+			}),
+		},
+		{
+			name: "login",
+			descr: "request a new consumer key and wait for its validation; " +
+				"the pending key is saved for -resume",
+			client: rawClient,
+			setup: func(fs *flag.FlagSet) runner {
+				var o loginOpts
+				fs.StringVar(&o.redirect, "redirect", "", "`url` to redirect to once the key is validated")
+				fs.StringVar(&o.pending, "pending", pendingFn, "pending key `file`")
+				fs.StringVar(&o.notify, "notify-cmd", "", "run `cmd` with sh(1), the validation URL and the pending key as $1 and $2")
+				fs.BoolVar(&o.nowait, "no-wait", false, "exit once the key is requested")
+				fs.BoolVar(&o.resume, "resume", false, "wait for the validation of the pending key")
+				return func(c *ovh.Client, as []string) error {
+					return login(c, o)
+				}
+			},
+		},
+		{
+			name:   "profiles",
+			descr:  "list the profiles of " + confFn + ", marking the current one, and whether they are validated",
+			client: noClient,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsProfiles()
+			}),
+		},
+		{
+			name:   "cache clear",
+			descr:  "remove all cached API resources",
+			client: noClient,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return clearCache()
+			}),
+		},
+		{
+			name:  "flush-credentials",
+			descr: "remove all expired credentials",
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return flushExpiredCredentials(c)
+			}),
+		},
+		{
+			name: "ls-creds",
+			descr: "list API credentials: id, application, status, creation, " +
+				"expiration, last use, allowed IPs, rules",
+			setup: func(fs *flag.FlagSet) runner {
+				mine := fs.Bool("mine", false, "only list our application's non-expired credentials")
+				return func(c *ovh.Client, as []string) error {
+					return lsCreds(c, *mine)
+				}
+			},
+		},
+		{
+			name:  "show-cred",
+			args:  "<id>",
+			descr: "show an API credential's details",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				i, err := strconv.Atoi(as[0])
+				if err != nil {
+					return fmt.Errorf("Invalid credential ID: '%s'", as[0])
+				}
+				return showCred(c, i)
+			}),
+		},
+		{
+			name: "rm-creds",
+			args: "[id ...]",
+			descr: "remove API credentials, by id or matching all the given " +
+				"criteria; the credential in use is only removed by id",
+			max: -1,
+			setup: func(fs *flag.FlagSet) runner {
+				var f credFilter
+				fs.BoolVar(&f.expired, "expired", false, "remove expired credentials")
+				fs.StringVar(&f.app, "app", "", "remove credentials of this application (`name` or id)")
+				fs.Func("older-than", "remove credentials created more than `duration` ago (e.g. 2w, 30d, 12h)",
+					func(s string) (err error) {
+						f.age, err = parseAge(s)
+						return err
+					})
+				return func(c *ovh.Client, as []string) error {
+					if len(as) == 0 && f.empty() {
+						return fmt.Errorf("No credential selected")
+					}
+					var is []int
+					for _, a := range as {
+						i, err := strconv.Atoi(a)
+						if err != nil {
+							return fmt.Errorf("Invalid credential ID: '%s'", a)
+						}
+						is = append(is, i)
+					}
+					return rmCreds(c, f, is)
+				}
+			},
+		},
+		{
+			name:  "ls-apps",
+			descr: "list registered API applications",
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsApps(c)
+			}),
+		},
+		{
+			name:  "rm-apps",
+			args:  "<name|id> ...",
+			descr: "remove API applications",
+			min:   1, max: -1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				for _, a := range as {
+					if err := rmApp(c, a); err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		},
+		{
+			name:  "ls-vps",
+			descr: "list VPSs, with their state, location, IPs, disk and memory",
+			setup: func(fs *flag.FlagSet) runner {
+				var f vpsFilter
+				j := fs.Int("j", fetchJobs, "number of VPSs fetched concurrently")
+				fs.StringVar(&f.state, "state", "", "only list VPSs in this `state` (e.g. running)")
+				fs.StringVar(&f.dc, "dc", "", "only list VPSs whose datacenter starts with `name` (e.g. gra)")
+				fs.Func("name", "only list VPSs whose name matches `regexp`", func(s string) (err error) {
+					f.name, err = regexp.Compile(s)
+					return err
+				})
+				fs.Func("ip", "only list VPSs with an IP in `cidr`, or equal to an IP", func(s string) error {
+					n, err := parseCIDR(s)
+					f.ip = n
+					return err
+				})
+				return func(c *ovh.Client, as []string) error {
+					return lsVPS(c, f, *j)
+				}
+			},
+		},
+		{
+			name:  "get-console",
+			args:  "<vps>",
+			vps:   true,
+			descr: "print a VPS' KVM console URL",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return getConsole(c, as[0])
+			}),
+		},
+		{
+			name:  "ls-ips",
+			args:  "<vps>",
+			vps:   true,
+			descr: "list a VPS' IPs",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsIPs(c, as[0])
+			}),
+		},
+		{
+			name:  "ls-keys",
+			descr: "list registered SSH keys",
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsKeys(c)
+			}),
+		},
+		{
+			name:  "rm-keys",
+			args:  "<key-name> ...",
+			descr: "remove registered SSH keys",
+			min:   1, max: -1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				for _, a := range as {
+					if err := rmKey(c, a); err != nil {
+						return err
+					}
+				}
+				return nil
+			}),
+		},
+		{
+			name: "add-key",
+			args: "[key-name [path/to/key|key]]",
+			descr: "register a SSH key (default: " + ovhKeyName +
+				", $HOME/.ssh/id_*.pub)",
+			max: 2,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				kn := ovhKeyName
+				if len(as) > 0 {
+					kn = as[0]
+				}
+				var k string
+				var err error
+				if len(as) > 1 {
+					k, err = readKeyArg(as[1])
+				} else {
+					k, err = readSSHKey()
+				}
+				if err != nil {
+					return err
+				}
+				fmt.Println(kn, k)
+				return addKey(c, kn, k)
+			}),
+		},
+		{
+			name:  "ls-imgs",
+			args:  "<vps>",
+			vps:   true,
+			descr: "list images available for a VPS",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsImgs(c, as[0])
+			}),
+		},
+		{
+			name:  "ls-img",
+			args:  "<vps> <name|regexp>",
+			vps:   true,
+			descr: "print the image that would be selected by rebuild",
+			min:   2, max: 2,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				id, name, err := getMatchingImg(c, as[0], as[1])
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%s\t%s\n", name, id)
+				return nil
+			}),
+		},
+		{
+			name: "rebuild",
+			args: "<vps> <img-id|img-name|regexp> [key-name]",
+			vps:  true,
+			descr: "reinstall a VPS; for a regexp, the most recent matching " +
+				"image is used",
+			min: 2, max: 3,
+			setup: func(fs *flag.FlagSet) runner {
+				dns := fs.String("dns", "", "register the VPS' IPs under this `fqdn`")
+				snap := fs.Bool("snapshot-first", false, "snapshot the VPS before reinstalling it")
+				return func(c *ovh.Client, as []string) error {
+					kn := ovhKeyName
+					if len(as) > 2 {
+						kn = as[2]
+					}
+					return rebuild(c, as[0], as[1], kn, *dns, *snap)
+				}
+			},
+		},
+		{
+			name:  "rebuild-debian",
+			args:  "<vps> [key-name]",
+			vps:   true,
+			descr: "reinstall a VPS with the most recent Debian",
+			min:   1, max: 2,
+			setup: func(fs *flag.FlagSet) runner {
+				dns := fs.String("dns", "", "register the VPS' IPs under this `fqdn`")
+				snap := fs.Bool("snapshot-first", false, "snapshot the VPS before reinstalling it")
+				return func(c *ovh.Client, as []string) error {
+					kn := ovhKeyName
+					if len(as) > 1 {
+						kn = as[1]
+					}
+					return rebuild(c, as[0], "Debian", kn, *dns, *snap)
+				}
+			},
+		},
+		{
+			name:  "start",
+			args:  "<vps>",
+			vps:   true,
+			descr: "start a VPS, and wait for it to be started",
+			min:   1, max: 1,
+			setup: func(fs *flag.FlagSet) runner {
+				ssh := fs.Bool("wait-ssh", false, "then wait for sshd to answer")
+				return func(c *ovh.Client, as []string) error {
+					if err := powerVPS(c, as[0], "start"); err != nil {
+						return err
+					}
+					if *ssh {
+						return waitSSH(c, as[0])
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:  "stop",
+			args:  "<vps>",
+			vps:   true,
+			descr: "stop a VPS, and wait for it to be stopped",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return powerVPS(c, as[0], "stop")
+			}),
+		},
+		{
+			name:  "reboot",
+			args:  "<vps>",
+			vps:   true,
+			descr: "reboot a VPS, and wait for it to be rebooted",
+			min:   1, max: 1,
+			setup: func(fs *flag.FlagSet) runner {
+				hard := fs.Bool("hard", false, "stop, then start the VPS")
+				ssh := fs.Bool("wait-ssh", false, "then wait for sshd to answer")
+				return func(c *ovh.Client, as []string) error {
+					var err error
+					if *hard {
+						err = hardRebootVPS(c, as[0])
+					} else {
+						err = powerVPS(c, as[0], "reboot")
+					}
+					if err != nil {
+						return err
+					}
+					if *ssh {
+						return waitSSH(c, as[0])
+					}
+					return nil
+				}
+			},
+		},
+		{
+			name:  "snapshot create",
+			args:  "<vps> [description]",
+			vps:   true,
+			descr: "snapshot a VPS, and wait for the snapshot to be done",
+			min:   1, max: 2,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				d := ""
+				if len(as) > 1 {
+					d = as[1]
+				}
+				return createSnapshot(c, as[0], d)
+			}),
+		},
+		{
+			name:  "snapshot show",
+			args:  "<vps>",
+			vps:   true,
+			descr: "show a VPS' snapshot: id, creation date, description, region",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return showSnapshot(c, as[0])
+			}),
+		},
+		{
+			name:  "snapshot rm",
+			args:  "<vps>",
+			vps:   true,
+			descr: "remove a VPS' snapshot",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return rmSnapshot(c, as[0])
+			}),
+		},
+		{
+			name:  "snapshot restore",
+			args:  "<vps>",
+			vps:   true,
+			descr: "revert a VPS to its snapshot",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return restoreSnapshot(c, as[0])
+			}),
+		},
+		{
+			name:  "snapshot download-url",
+			args:  "<vps>",
+			vps:   true,
+			descr: "print a download URL for a VPS' snapshot",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return getSnapshotURL(c, as[0])
+			}),
+		},
+		{
+			name:  "ls-backups",
+			args:  "<vps>",
+			vps:   true,
+			descr: "list a VPS' automated backups restore points, most recent first",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsBackups(c, as[0])
+			}),
+		},
+		{
+			name: "restore-backup",
+			args: "<vps> <date|index|latest>",
+			vps:  true,
+			descr: "restore a VPS from an automated backup; index 0 is the " +
+				"latest backup",
+			min: 2, max: 2,
+			setup: func(fs *flag.FlagSet) runner {
+				m := fs.String("mode", "file", "`mode`: full (replace the VPS' disk), or file "+
+					"(attach the backup, and print how to access it)")
+				return func(c *ovh.Client, as []string) error {
+					return restoreBackup(c, as[0], as[1], *m)
+				}
+			},
+		},
+		{
+			name:  "ls-tasks",
+			args:  "<vps>",
+			vps:   true,
+			descr: "list a VPS' tasks: id, type, state, progress, date",
+			min:   1, max: 1,
+			setup: func(fs *flag.FlagSet) runner {
+				st := fs.String("state", "", "only list tasks in this `state` (e.g. doing, error)")
+				return func(c *ovh.Client, as []string) error {
+					return lsTasks(c, as[0], *st)
+				}
+			},
+		},
+		{
+			name: "wait-task",
+			args: "<vps> <id>",
+			vps:  true,
+			descr: "wait for a VPS' task to be done; interrupted rebuilds are " +
+				"finished (known hosts reset, DNS registration)",
+			min: 2, max: 2,
+			setup: func(fs *flag.FlagSet) runner {
+				dns := fs.String("dns", "", "rebuilds: register the VPS' IPs under this `fqdn`")
+				return func(c *ovh.Client, as []string) error {
+					i, err := strconv.Atoi(as[1])
+					if err != nil {
+						return fmt.Errorf("Invalid task ID: '%s'", as[1])
+					}
+					return waitTask(c, as[0], i, *dns)
+				}
+			},
+		},
+		{
+			name:  "ls-zones",
+			descr: "list DNS zones",
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsZones(c)
+			}),
+		},
+		{
+			name:  "get-zone",
+			args:  "<zone>",
+			descr: "print a DNS zone, in BIND format",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return getZone(c, as[0])
+			}),
+		},
+		{
+			name:  "put-zone",
+			args:  "<zone> <file|->",
+			descr: "replace a DNS zone by a BIND zone file (- for stdin)",
+			min:   2, max: 2,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return putZone(c, as[0], as[1])
+			}),
+		},
+		{
+			name:  "ls-zone-backups",
+			args:  "<zone>",
+			descr: "list a DNS zone's backups",
+			min:   1, max: 1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return lsZoneBackups(c, as[0])
+			}),
+		},
+		{
+			name: "restore-zone",
+			args: "<zone> <creation-date|index|latest>",
+			descr: "restore a DNS zone from a backup; index 0 is the " +
+				"latest backup",
+			min: 2, max: 2,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				return restoreZone(c, as[0], as[1])
+			}),
+		},
+		{
+			name: "diff-zone",
+			args: "<zone> [file|-|creation-date|index|latest]",
+			descr: "compare a live DNS zone with a BIND zone file, or a " +
+				"backup (default: latest)",
+			min: 1, max: 2,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				s := ""
+				if len(as) > 1 {
+					s = as[1]
+				}
+				return diffZone(c, as[0], s)
+			}),
+		},
+		{
+			name:  "ls-records",
+			args:  "<zone>",
+			descr: "list a DNS zone's records: id, sub-domain, TTL, type, target",
+			min:   1, max: 1,
+			setup: func(fs *flag.FlagSet) runner {
+				t := fs.String("t", "", "only list records of this `type` (A, AAAA, MX, ...)")
+				s := fs.String("s", "", "only list records of this `sub-domain` (@ for the apex)")
+				return func(c *ovh.Client, as []string) error {
+					return lsRecords(c, as[0], *t, *s)
+				}
+			},
+		},
+		{
+			name:  "add-record",
+			args:  "<zone> <type> <sub-domain|@> <target>",
+			descr: "add a DNS record, print its id",
+			min:   4, max: 4,
+			setup: func(fs *flag.FlagSet) runner {
+				ttl := fs.Int("ttl", 0, "record's `ttl` (0 for the zone's default)")
+				return func(c *ovh.Client, as []string) error {
+					i, err := addRecord(c, as[0], as[1], as[2], as[3], *ttl)
+					if err != nil {
+						return err
+					}
+					if err = refreshZone(c, as[0]); err != nil {
+						return err
+					}
+					fmt.Println(i)
+					return nil
+				}
+			},
+		},
+		{
+			name:  "set-record",
+			args:  "<zone> <id>",
+			descr: "update a DNS record's target and/or TTL",
+			min:   2, max: 2,
+			setup: func(fs *flag.FlagSet) runner {
+				v := fs.String("target", "", "new `target`")
+				ttl := fs.Int("ttl", -1, "new `ttl` (0 for the zone's default)")
+				return func(c *ovh.Client, as []string) error {
+					if *v == "" && *ttl < 0 {
+						return fmt.Errorf("Nothing to update")
+					}
+					i, err := strconv.Atoi(as[1])
+					if err != nil {
+						return fmt.Errorf("Invalid record ID: '%s'", as[1])
+					}
+					var pttl *int
+					if *ttl >= 0 {
+						pttl = ttl
+					}
+					if err = setRecord(c, as[0], i, *v, pttl); err != nil {
+						return err
+					}
+					return refreshZone(c, as[0])
+				}
+			},
+		},
+		{
+			name:  "rm-record",
+			args:  "<zone> <id> ...",
+			descr: "remove DNS records",
+			min:   2, max: -1,
+			setup: noFlags(func(c *ovh.Client, as []string) error {
+				for _, a := range as[1:] {
+					i, err := strconv.Atoi(a)
+					if err != nil {
+						return fmt.Errorf("Invalid record ID: '%s'", a)
+					}
+					if err = rmRecord(c, as[0], i); err != nil {
+						return err
+					}
+				}
+				return refreshZone(c, as[0])
+			}),
+		},
+		{
+			name: "sync-zone",
+			args: "<zone> <spec.yaml|spec.toml>",
+			descr: "print, and optionally apply, the changes for a DNS " +
+				"zone's records to match a specification",
+			min: 2, max: 2,
+			setup: func(fs *flag.FlagSet) runner {
+				plan := fs.Bool("plan", false, "only print the changes (default)")
+				apply := fs.Bool("apply", false, "print and apply the changes")
+				prune := fs.Bool("prune", false, "delete records absent from the spec")
+				return func(c *ovh.Client, as []string) error {
+					if *plan && *apply {
+						return fmt.Errorf("-plan and -apply are mutually exclusive")
+					}
+					return syncZone(c, as[0], as[1], *apply, *prune)
+				}
+			},
+		},
+	}
+}
+
+func findCmd(n string) *cmd {
+	for _, x := range cmds {
+		if x.name == n {
+			return x
+		}
+	}
+	return nil
+}
+
+// find the command named by the first words of xs (e.g.
+// "snapshot create"); returns the remaining words.
+func lookupCmd(xs []string) (*cmd, []string) {
+	for n := 2; n > 0; n-- {
+		if len(xs) < n {
+			continue
+		}
+		if x := findCmd(strings.Join(xs[:n], " ")); x != nil {
+			return x, xs[n:]
+		}
+	}
+	return nil, xs
+}
+
+// parse fs's flags from xs, allowing flags and arguments to
+// be intermixed; returns the arguments.
+func parseArgs(fs *flag.FlagSet, xs []string) ([]string, error) {
+	var as []string
+	for {
+		if err := fs.Parse(xs); err != nil {
+			return nil, err
+		}
+		ys := fs.Args()
+
+		// "--": everything left is an argument
+		if n := len(xs) - len(ys); n > 0 && xs[n-1] == "--" {
+			return append(as, ys...), nil
+		}
+		if len(ys) == 0 {
+			return as, nil
+		}
+		as = append(as, ys[0])
+		xs = ys[1:]
+	}
+}
+
+// x's flag set, and the function running x
+func (x *cmd) flags() (*flag.FlagSet, runner) {
+	fs := flag.NewFlagSet(x.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	f := x.setup(fs)
+	return fs, f
+}
+
+// one-line synopsis, generated from x's declaration
+func (x *cmd) synopsis(fs *flag.FlagSet) string {
+	xs := []string{"ovh-do", x.name}
+	fs.VisitAll(func(f *flag.Flag) {
+		n, _ := flag.UnquoteUsage(f)
+		if n == "" {
+			xs = append(xs, "[-"+f.Name+"]")
+		} else {
+			xs = append(xs, "[-"+f.Name+" "+n+"]")
+		}
+	})
+	if x.args != "" {
+		xs = append(xs, x.args)
+	}
+	return strings.Join(xs, " ")
+}
+
+func (x *cmd) usage(w io.Writer) {
+	fs, _ := x.flags()
+	fmt.Fprintf(w, "usage: %s\n\n%s\n", x.synopsis(fs), x.descr)
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	if n > 0 {
+		fmt.Fprintf(w, "\nOptions:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+// parse x's arguments from xs; on failure (or -h), print
+// x's usage and exit.
+func (x *cmd) parse(xs []string) (runner, []string) {
+	fs, f := x.flags()
+	as, err := parseArgs(fs, xs)
+	if errors.Is(err, flag.ErrHelp) {
+		x.usage(os.Stdout)
+		os.Exit(0)
+	}
+	if err == nil && (len(as) < x.min || (x.max >= 0 && len(as) > x.max)) {
+		err = fmt.Errorf("invalid number of arguments")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ovh-do %s: %s\n", x.name, err)
+		x.usage(os.Stderr)
+		os.Exit(1)
+	}
+	return f, as
+}
+
+// print the general usage
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: ovh-do [options] <command> [arguments]\n\nCommands:\n")
+
+	ns := make([]string, 0, len(cmds))
+	for _, x := range cmds {
+		ns = append(ns, x.name)
+	}
+	sort.Strings(ns)
+	for _, n := range ns {
+		fmt.Fprintf(w, "  %-22s %s\n", n, findCmd(n).descr)
+	}
+
+	fmt.Fprintf(w, "\nOptions:\n")
+	o := flag.CommandLine.Output()
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+	flag.CommandLine.SetOutput(o)
+
+	fmt.Fprintf(w, "\nRun 'ovh-do help <command>' for a command's usage.\n")
+}
+
+// help [command]; exits with n
+func help(n int, as []string) {
+	w := os.Stdout
+	if n != 0 {
+		w = os.Stderr
+	}
+	if len(as) == 0 {
+		usage(w)
+		os.Exit(n)
+	}
+	x, _ := lookupCmd(as)
+	if x == nil {
+		fmt.Fprintf(os.Stderr, "ovh-do: unknown command '%s'\n", strings.Join(as, " "))
+		usage(os.Stderr)
+		os.Exit(1)
+	}
+	x.usage(w)
+	os.Exit(n)
+}
+
+// run the command described by the (non-global) arguments
+// xs; exits on failure.
+func runCmd(xs []string) {
+	if len(xs) == 0 {
+		help(1, nil)
+	}
+
+	x, xs := lookupCmd(xs)
+	if x == nil {
+		fmt.Fprintf(os.Stderr, "ovh-do: unknown command '%s'\n", xs[0])
+		usage(os.Stderr)
+		os.Exit(1)
+	}
+	f, as := x.parse(xs)
+
+	// only contact the API when needed
+	var c *ovh.Client
+	var err error
+	switch x.client {
+	case needClient:
+		c, err = getClient()
+	case rawClient:
+		c, err = newClient()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if x.vps && len(as) > 0 {
+		if as[0], err = resolveVPS(c, as[0]); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := f(c, as); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+// ovh-do's own settings: [ovh-do] section of confFn, which
+// can be overriden by global flags.
+
+import (
+	"flag"
+	"fmt"
+	"gopkg.in/ini.v1"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// [ovh-do] section name in confFn
+var confSection = "ovh-do"
+
+func init() {
+	// key=value, as expected by other OVH API wrappers
+	ini.PrettyFormat = false
+}
+
+// name of f's section holding the API credentials, as go-ovh
+// selects it: $OVH_ENDPOINT, [default]'s endpoint, or ovh-eu
+func confEndpoint(f *ini.File) string {
+	if s := os.Getenv("OVH_ENDPOINT"); s != "" {
+		return s
+	}
+	// nb. f.Section() and Key() would create missing entries
+	if s, err := f.GetSection("default"); err == nil && s.HasKey("endpoint") {
+		return s.Key("endpoint").String()
+	}
+	return "ovh-eu"
+}
+
+// atomically replace confFn by f; confFn is only readable
+// by its owner.
+func writeConf(f *ini.File) error {
+	fn := confFn
+	// e.g. dotfiles managed with symlinks
+	if x, err := filepath.EvalSymlinks(fn); err == nil {
+		fn = x
+	}
+
+	t, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(t.Name())
+
+	if err := t.Chmod(0600); err != nil {
+		t.Close()
+		return err
+	}
+	if _, err := f.WriteTo(t); err != nil {
+		t.Close()
+		return err
+	}
+	if err := t.Sync(); err != nil {
+		t.Close()
+		return err
+	}
+	if err := t.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(t.Name(), fn)
+}
+
+// set the key k to v in confFn's section s (default: the
+// credentials' section, see confEndpoint()). The file and
+// the section are created if needed; other sections, keys
+// and comments are preserved.
+func setConfKey(s, k, v string) error {
+	f, err := ini.LooseLoad(confFn)
+	if err != nil {
+		return err
+	}
+	if s == "" {
+		s = confEndpoint(f)
+	}
+	f.Section(s).Key(k).SetValue(v)
+	return writeConf(f)
+}
+
+// set all pooling timeouts to d
+func setTimeout(d time.Duration) {
+	poolValidatedTimeout = d
+	poolRebuildTimeout = d
+	poolZoneTaskTimeout = d
+}
+
+// load our settings from confFn, e.g.
+//
+//	[ovh-do]
+//	timeout=15m
+//	poll_interval=10s
+//	wait_up=30s
+//	jobs=4
+//	progress=log
+//	cache.images=48h
+//	scope=ci
+//	scope.ci=RO /vps, RO /vps/*
+//
+// A missing confFn is not an error.
+func loadConf() error {
+	f, err := ini.LooseLoad(confFn)
+	if err != nil {
+		return fmt.Errorf("Loading %s: %s", confFn, err)
+	}
+	s := f.Section(confSection)
+
+	for _, x := range []struct {
+		k string
+		f func(time.Duration)
+	}{
+		{"timeout", setTimeout},
+		{"poll_interval", func(d time.Duration) { poolInterval = d }},
+		{"wait_up", func(d time.Duration) { waitVPSUp = d }},
+	} {
+		if !s.HasKey(x.k) {
+			continue
+		}
+		d, err := s.Key(x.k).Duration()
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%s: [%s] %s: invalid duration '%s'",
+				confFn, confSection, x.k, s.Key(x.k).String())
+		}
+		x.f(d)
+	}
+
+	if s.HasKey("progress") {
+		if err := setProgressMode(s.Key("progress").String()); err != nil {
+			return fmt.Errorf("%s: [%s] progress: %s", confFn, confSection, err)
+		}
+	}
+
+	if s.HasKey("jobs") {
+		n, err := s.Key("jobs").Int()
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s: [%s] jobs: invalid number '%s'",
+				confFn, confSection, s.Key("jobs").String())
+		}
+		fetchJobs = n
+	}
+
+	for _, k := range s.Keys() {
+		if n := strings.TrimPrefix(k.Name(), "cache."); n != k.Name() {
+			d, err := k.Duration()
+			if err != nil || d < 0 {
+				return fmt.Errorf("%s: [%s] %s: invalid duration '%s'",
+					confFn, confSection, k.Name(), k.String())
+			}
+			if err := setCacheTTL(n, d); err != nil {
+				return fmt.Errorf("%s: [%s] %s: %s", confFn, confSection, k.Name(), err)
+			}
+		}
+	}
+
+	// custom scopes first, so they can be selected
+	for _, k := range s.Keys() {
+		if n := strings.TrimPrefix(k.Name(), "scope."); n != k.Name() && n != "" {
+			if _, err := parseScope(k.String()); err != nil {
+				return fmt.Errorf("%s: [%s] %s: %s", confFn, confSection, k.Name(), err)
+			}
+			scopes[n] = k.String()
+		}
+	}
+	if s.HasKey("scope") {
+		if err := setScope(s.Key("scope").String()); err != nil {
+			return fmt.Errorf("%s: [%s] scope: %s", confFn, confSection, err)
+		}
+	}
+
+	return nil
+}
+
+// parse the global flags, preceding the command name; returns
+// the remaining arguments.
+func parseGlobalFlags() []string {
+	var t time.Duration
+
+	flag.DurationVar(&t, "t", 0, "pooling timeout (validation, rebuild, tasks)")
+	flag.DurationVar(&t, "timeout", 0, "pooling timeout (validation, rebuild, tasks)")
+	flag.DurationVar(&poolInterval, "poll-interval", poolInterval, "delay between two pooling API calls")
+	flag.DurationVar(&waitVPSUp, "wait-up", waitVPSUp, "delay for a rebuilt VPS to be up")
+	flag.Func("o", "listings `format`: "+strings.Join(outputFmts, ", ")+" (default text)", setOutputFmt)
+	flag.Func("output", "listings `format`: "+strings.Join(outputFmts, ", ")+" (default text)", setOutputFmt)
+	flag.Func("format", "print listed items with this Go text/template `template`", setOutputTmpl)
+	flag.Func("scope", "access rules requested for new consumer keys: `name` among "+
+		strings.Join(scopeNames(), ", ")+", or defined in "+confFn+" (default "+scope+")", setScope)
+	flag.IntVar(&fetchJobs, "jobs", fetchJobs, "number of concurrent API fetches for listings")
+	flag.BoolVar(&keepGoing, "keep-going", false, "listings: skip items failing to be fetched, and report them afterwards")
+	flag.Func("progress", "progress reports `mode`: "+strings.Join(progressModes, ", ")+" (default auto)", setProgressMode)
+	flag.BoolVar(&noCache, "no-cache", false, "don't use cached API resources (they're still updated)")
+	flag.StringVar(&profile, "p", profile, "`profile`, i.e. "+confFn+" section, to use; $OVH_DO_PROFILE by default")
+	flag.StringVar(&profile, "profile", profile, "`profile`, i.e. "+confFn+" section, to use; $OVH_DO_PROFILE by default")
+	flag.Usage = func() { usage(flag.CommandLine.Output()) }
+	flag.Parse()
+
+	if t > 0 {
+		setTimeout(t)
+	}
+	if poolInterval <= 0 {
+		poolInterval = time.Second
+	}
+
+	return flag.Args()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConf(t *testing.T) {
+	fn := confFn
+	defer func() { confFn = fn }()
+	confFn = filepath.Join(t.TempDir(), "ovh.conf")
+
+	// loadConf() and its resulting globals
+	f := func(s string) (error, time.Duration, time.Duration, time.Duration) {
+		poolRebuildTimeout, poolInterval, waitVPSUp = time.Minute, time.Second, time.Second
+		if err := os.WriteFile(confFn, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := loadConf()
+		return err, poolRebuildTimeout, poolInterval, waitVPSUp
+	}
+
+	doTests(t, []test{
+		{
+			"no section",
+			f,
+			[]interface{}{"[ovh-eu]\nconsumer_key=x\n"},
+			[]interface{}{nil, time.Minute, time.Second, time.Second},
+		},
+		{
+			"all keys",
+			f,
+			[]interface{}{"[ovh-do]\ntimeout=15m\npoll_interval=10s\nwait_up=30s\n"},
+			[]interface{}{nil, 15 * time.Minute, 10 * time.Second, 30 * time.Second},
+		},
+		{
+			"invalid duration",
+			f,
+			[]interface{}{"[ovh-do]\ntimeout=soon\n"},
+			[]interface{}{
+				fmt.Errorf("%s: [ovh-do] timeout: invalid duration 'soon'", confFn),
+				time.Minute, time.Second, time.Second,
+			},
+		},
+	})
+}
+
+func TestSetConfKey(t *testing.T) {
+	fn := confFn
+	defer func() { confFn = fn }()
+	confFn = filepath.Join(t.TempDir(), "ovh.conf")
+
+	// setConfKey() on the file content s; returns the new
+	// content and permissions
+	f := func(s, sec, k, v string) (string, os.FileMode, error) {
+		os.Remove(confFn)
+		if s != "" {
+			if err := os.WriteFile(confFn, []byte(s), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := setConfKey(sec, k, v); err != nil {
+			return "", 0, err
+		}
+		x, err := os.ReadFile(confFn)
+		if err != nil {
+			return "", 0, err
+		}
+		st, err := os.Stat(confFn)
+		if err != nil {
+			return "", 0, err
+		}
+		return string(x), st.Mode().Perm(), nil
+	}
+
+	doTests(t, []test{
+		{
+			"missing file",
+			f,
+			[]interface{}{"", "", "consumer_key", "k"},
+			[]interface{}{"[ovh-eu]\nconsumer_key=k\n\n", os.FileMode(0600), nil},
+		},
+		{
+			"only the endpoint's section is updated",
+			f,
+			[]interface{}{`; OVH API
+[default]
+endpoint=ovh-ca
+
+[ovh-eu]
+consumer_key=eu
+
+[ovh-ca]
+# secret
+application_secret=s
+consumer_key=ca
+`, "", "consumer_key", "k"},
+			[]interface{}{`; OVH API
+[default]
+endpoint=ovh-ca
+
+[ovh-eu]
+consumer_key=eu
+
+[ovh-ca]
+# secret
+application_secret=s
+consumer_key=k
+
+`, os.FileMode(0600), nil},
+		},
+		{
+			"new section",
+			f,
+			[]interface{}{"[ovh-eu]\nconsumer_key=eu\n", "ovh-do", "timeout", "10m"},
+			[]interface{}{"[ovh-eu]\nconsumer_key=eu\n\n[ovh-do]\ntimeout=10m\n\n", os.FileMode(0600), nil},
+		},
+	})
+}
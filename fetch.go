@@ -0,0 +1,191 @@
+package main
+
+// Concurrent API fetches: bounded worker pools, with
+// rate-limiting (HTTP 429) retries.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default number of concurrent fetches (see -jobs)
+var fetchJobs = 8
+
+// skip listed items failing to be fetched (see -keep-going)
+var keepGoing = false
+
+// rate-limited requests are retried up to fetchRetries
+// times, waiting fetchRetryDelay, doubled after each try.
+var fetchRetries = 5
+var fetchRetryDelay = time.Second
+
+// per-item errors, as collected by forEachItemConc()
+type itemErrors []error
+
+func (xs itemErrors) Error() string {
+	ys := make([]string, len(xs))
+	for i, x := range xs {
+		ys[i] = x.Error()
+	}
+	return strings.Join(ys, "\n")
+}
+
+// forEachItemConc() options
+type eachOpts struct {
+	// concurrent fetches; fetchJobs if <= 0
+	jobs int
+
+	// if set, failing items are skipped and their errors
+	// returned once all items have been processed, as an
+	// itemErrors.
+	keepGoing bool
+}
+
+// go-ovh's clients can't be shared by goroutines (each
+// request sets c.Client.Timeout): workers use copies.
+// Copies share c's time delta, if already computed.
+func cloneClient(c *ovh.Client) *ovh.Client {
+	x := *c
+	if c.Client != nil {
+		y := *c.Client
+		x.Client = &y
+	}
+	return &x
+}
+
+func isRateLimited(err error) bool {
+	var aerr *ovh.APIError
+	return errors.As(err, &aerr) && aerr.Code == http.StatusTooManyRequests
+}
+
+// c.Get(), retried while rate-limited; cached (see cachedGet())
+func getRetry(ctx context.Context, c *ovh.Client, p string, y interface{}) error {
+	d := fetchRetryDelay
+	for i := 0; ; i++ {
+		err := cachedGet(ctx, c, p, y)
+		if err == nil || i == fetchRetries || !isRateLimited(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+		d *= 2
+	}
+}
+
+// call f(ctx, i) for i in [0, n[, with at most j concurrent
+// calls. Once ctx is done, remaining calls are skipped.
+func runJobs(ctx context.Context, n, j int, f func(context.Context, int)) {
+	if j <= 0 {
+		j = fetchJobs
+	}
+	if j <= 0 {
+		j = 1
+	}
+
+	is := make(chan int)
+	var wg sync.WaitGroup
+	for k := 0; k < j && k < n; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range is {
+				f(ctx, i)
+			}
+		}()
+	}
+
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break loop
+		case is <- i:
+		}
+	}
+	close(is)
+	wg.Wait()
+}
+
+// concurrent forEachItem(): items are fetched by a pool of
+// o.jobs workers, but f is still called sequentially, in
+// the listing's order.
+func forEachItemConc[T Item, U ItemId](ctx context.Context, c *ovh.Client, r string,
+	f func(T) (bool, error), g func(U) string, o eachOpts) error {
+	var xs []U
+	if err := getRetry(ctx, c, r, &xs); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p, _, _ := strings.Cut(r, "?")
+	ys := make([]T, len(xs))
+	errs := make([]error, len(xs))
+	dones := make([]chan struct{}, len(xs))
+	for i := range dones {
+		dones[i] = make(chan struct{})
+	}
+
+	go runJobs(ctx, len(xs), o.jobs, func(ctx context.Context, i int) {
+		defer close(dones[i])
+		if err := getRetry(ctx, cloneClient(c), p+"/"+g(xs[i]), &ys[i]); err != nil {
+			errs[i] = fmt.Errorf("%s/%s: %s", p, g(xs[i]), err)
+		}
+	})
+
+	var es itemErrors
+	for i := range xs {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-dones[i]:
+		}
+
+		err, stop := errs[i], false
+		if err == nil {
+			stop, err = f(ys[i])
+		}
+		if err != nil {
+			if !o.keepGoing {
+				return err
+			}
+			es = append(es, err)
+		}
+		if stop {
+			break
+		}
+	}
+
+	if len(es) > 0 {
+		return es
+	}
+	return nil
+}
+
+// list r's items with l, fetched concurrently. With keepGoing,
+// failing items are reported once the listing is done.
+func listItems[T Item, U ItemId](c *ovh.Client, r string, l *lister[T], g func(U) string) error {
+	var es itemErrors
+
+	err := forEachItemConc(context.Background(), c, r, l.each, g, eachOpts{keepGoing: keepGoing})
+	if err != nil && !errors.As(err, &es) {
+		return err
+	}
+	if err := l.done(); err != nil {
+		return err
+	}
+	if len(es) > 0 {
+		return es
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEachItemConc(t *testing.T) {
+	var mu sync.Mutex
+	limited := map[string]bool{}
+
+	// /items/b is rate-limited once, /items/c is missing
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch p := r.URL.Path; p {
+		case "/auth/time":
+			fmt.Fprint(w, time.Now().Unix())
+		case "/items":
+			fmt.Fprint(w, `["a", "b", "c", "d"]`)
+		case "/items/b":
+			if !limited[p] {
+				limited[p] = true
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(w, `{"message": "slow down"}`)
+				return
+			}
+			fallthrough
+		case "/items/a", "/items/d":
+			fmt.Fprintf(w, `{"keyName": "%s"}`, strings.TrimPrefix(p, "/items/"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer s.Close()
+
+	c, err := ovh.NewClient(s.URL, "ak", "as", "ck")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := fetchRetryDelay
+	defer func() { fetchRetryDelay = d }()
+	fetchRetryDelay = time.Millisecond
+
+	// names of the listed items, until stop, and error
+	f := func(stop string, o eachOpts) ([]string, string) {
+		var xs []string
+		limited = map[string]bool{}
+		err := forEachItemConc(context.Background(), c, "/items",
+			func(y GetMeSSHKeyName) (bool, error) {
+				xs = append(xs, y.KeyName)
+				return y.KeyName == stop, nil
+			}, id[string], o)
+		if err != nil {
+			return xs, err.Error()
+		}
+		return xs, ""
+	}
+
+	notFound := `/items/c: HTTP Error 404: "not found"`
+
+	doTests(t, []test{
+		{
+			"first error",
+			f,
+			[]interface{}{"", eachOpts{jobs: 3}},
+			[]interface{}{[]string{"a", "b"}, notFound},
+		},
+		{
+			"keep going",
+			f,
+			[]interface{}{"", eachOpts{jobs: 2, keepGoing: true}},
+			[]interface{}{[]string{"a", "b", "d"}, notFound},
+		},
+		{
+			"stop",
+			f,
+			[]interface{}{"b", eachOpts{jobs: 1}},
+			[]interface{}{[]string{"a", "b"}, ""},
+		},
+	})
+}
@@ -2,6 +2,9 @@ module github.com/mbivert/ovh-tools
 
 go 1.19
 
-require github.com/ovh/go-ovh v1.2.0
-
-require gopkg.in/ini.v1 v1.57.0 // indirect
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/ovh/go-ovh v1.2.0
+	gopkg.in/ini.v1 v1.57.0
+	gopkg.in/yaml.v3 v3.0.1
+)
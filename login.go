@@ -0,0 +1,136 @@
+package main
+
+// Consumer keys validation, possibly in multiple steps
+// (e.g. headless setups).
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"gopkg.in/ini.v1"
+	"log"
+	"os"
+	"os/exec"
+)
+
+// default file holding a consumer key pending validation
+var pendingFn = confFn + ".pending"
+
+type loginOpts struct {
+	redirect string
+	pending  string
+	notify   string
+	nowait   bool
+	resume   bool
+}
+
+// save s, requested by the application ak for the current
+// profile (see -profile), to fn
+func writePending(fn, ak string, s *ovh.CkValidationState) error {
+	x := fmt.Sprintf("profile=%s\napplication_key=%s\nconsumer_key=%s\nvalidation_url=%s\n",
+		profile, ak, s.ConsumerKey, s.ValidationURL)
+	return os.WriteFile(fn, []byte(x), 0600)
+}
+
+// read the key pending in fn; it must have been requested
+// by the application ak, for the current profile.
+func readPending(fn, ak string) (*ovh.CkValidationState, error) {
+	f, err := ini.Load(fn)
+	if err != nil {
+		return nil, err
+	}
+	s := f.Section("")
+	if x := s.Key("profile").String(); x != profile {
+		return nil, fmt.Errorf("%s: key pending for profile '%s', not '%s'", fn, x, profile)
+	}
+	if x := s.Key("application_key").String(); x != ak {
+		return nil, fmt.Errorf("%s: key pending for application '%s', not '%s'", fn, x, ak)
+	}
+	x := ovh.CkValidationState{
+		ConsumerKey:   s.Key("consumer_key").String(),
+		State:         "pendingValidation",
+		ValidationURL: s.Key("validation_url").String(),
+	}
+	if x.ConsumerKey == "" {
+		return nil, fmt.Errorf("%s: no consumer_key", fn)
+	}
+	return &x, nil
+}
+
+// run the notification command n for s
+func notifyPending(n string, s *ovh.CkValidationState) error {
+	cmd := exec.Command("sh", "-c", n, "sh", s.ValidationURL, s.ConsumerKey)
+	cmd.Env = append(os.Environ(),
+		"OVH_DO_VALIDATION_URL="+s.ValidationURL,
+		"OVH_DO_CONSUMER_KEY="+s.ConsumerKey,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Running '%s': %s", n, err)
+	}
+	return nil
+}
+
+// wait for the validation of c's consumer key, save it,
+// and remove the pending file fn.
+func waitPending(c *ovh.Client, fn string) error {
+	ok, err := isValidated(c)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Println("Waiting for credentials to be validated...")
+		if err := poolForValidated(c); err != nil {
+			return err
+		}
+	}
+
+	if err := editNewKey(c.ConsumerKey); err != nil {
+		return fmt.Errorf("Editing %s: %s", confFn, err)
+	}
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	log.Println("Consumer key validated")
+	return nil
+}
+
+func login(c *ovh.Client, o loginOpts) error {
+	if o.resume {
+		s, err := readPending(o.pending, c.AppKey)
+		if err != nil {
+			return err
+		}
+		c.ConsumerKey = s.ConsumerKey
+		fmt.Printf("Consumer key:   %s\n", s.ConsumerKey)
+		fmt.Printf("Validation URL: %s\n", s.ValidationURL)
+		return waitPending(c, o.pending)
+	}
+
+	s, err := requestKey(c, o.redirect)
+	if err != nil {
+		return err
+	}
+
+	// saved first, so that it can be resumed whatever happens
+	if err := writePending(o.pending, c.AppKey, s); err != nil {
+		return err
+	}
+
+	fmt.Printf("Consumer key:   %s\n", s.ConsumerKey)
+	fmt.Printf("Validation URL: %s\n", s.ValidationURL)
+
+	if o.notify != "" {
+		if err := notifyPending(o.notify, s); err != nil {
+			return err
+		}
+	}
+
+	if o.nowait {
+		log.Printf("Pending key saved to %s; use login -resume once validated\n", o.pending)
+		return nil
+	}
+
+	return waitPending(c, o.pending)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPending(t *testing.T) {
+	d := t.TempDir()
+	var x *ovh.CkValidationState
+	f := func(s *ovh.CkValidationState) (*ovh.CkValidationState, error) {
+		fn := filepath.Join(d, "pending")
+		if err := writePending(fn, "ak", s); err != nil {
+			return nil, err
+		}
+		return readPending(fn, "ak")
+	}
+
+	// pending for the profile p, read from the profile q
+	g := func(p, q string) error {
+		defer func(x string) { profile = x }(profile)
+		fn := filepath.Join(d, "profile")
+		profile = p
+		if err := writePending(fn, "ak", x); err != nil {
+			return err
+		}
+		profile = q
+		_, err := readPending(fn, "ak")
+		return err
+	}
+
+	x = &ovh.CkValidationState{
+		ConsumerKey:   "MtSwSrPpNjqfVSmJhLbPyr2i45lSwPU1",
+		State:         "pendingValidation",
+		ValidationURL: "https://eu.api.ovh.com/auth/?credentialToken=iQ1joJE0OmSPlUAoSw1IvAPWDeaD87ZM64HEDvYq77IKIxr4bIu6fU8OtrPQEeRh",
+	}
+
+	fn := filepath.Join(d, "empty")
+	if err := os.WriteFile(fn, []byte("application_key=ak\nvalidation_url=x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	doTests(t, []test{
+		{
+			"round-trip",
+			f,
+			[]interface{}{x},
+			[]interface{}{x, nil},
+		},
+		{
+			"no consumer key",
+			readPending,
+			[]interface{}{fn, "ak"},
+			[]interface{}{(*ovh.CkValidationState)(nil), fmt.Errorf("%s: no consumer_key", fn)},
+		},
+		{
+			"another application",
+			readPending,
+			[]interface{}{fn, "ak2"},
+			[]interface{}{(*ovh.CkValidationState)(nil),
+				fmt.Errorf("%s: key pending for application 'ak', not 'ak2'", fn)},
+		},
+		{
+			"same profile",
+			g,
+			[]interface{}{"a", "a"},
+			[]interface{}{nil},
+		},
+		{
+			"another profile",
+			g,
+			[]interface{}{"a", ""},
+			[]interface{}{fmt.Errorf("%s: key pending for profile 'a', not ''",
+				filepath.Join(d, "profile"))},
+		},
+	})
+}
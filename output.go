@@ -0,0 +1,211 @@
+package main
+
+// Listings output: ad-hoc text (default), or generated
+// from the listed items (JSON, TSV, YAML, text/template).
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// text, json, tsv or yaml (see -output)
+var outputFmt = "text"
+
+// if set, used instead of outputFmt (see -format)
+var outputTmpl *template.Template
+
+var outputFmts = []string{"text", "json", "tsv", "yaml"}
+
+var out io.Writer = os.Stdout
+
+func setOutputFmt(s string) error {
+	for _, x := range outputFmts {
+		if x == s {
+			outputFmt = s
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid output format '%s' (%s)", s, strings.Join(outputFmts, ", "))
+}
+
+func setOutputTmpl(s string) error {
+	t, err := template.New("format").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(s)
+	if err != nil {
+		return err
+	}
+	outputTmpl = t
+	return nil
+}
+
+// Print a listing of T items. In text mode, items are
+// printed by the text function.
+//
+// JSON and YAML listings are printed as a whole once
+// done() is called; other formats are printed as items
+// are added.
+type lister[T any] struct {
+	text func(T)
+	xs   []T
+	n    int
+}
+
+func newLister[T any](text func(T)) *lister[T] {
+	return &lister[T]{text: text}
+}
+
+func (l *lister[T]) add(x T) error {
+	defer func() { l.n++ }()
+
+	if outputTmpl != nil {
+		if err := outputTmpl.Execute(out, x); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out)
+		return err
+	}
+
+	switch outputFmt {
+	case "json", "yaml":
+		l.xs = append(l.xs, x)
+	case "tsv":
+		ns, vs := tsvFields(reflect.ValueOf(x), "")
+		if l.n == 0 && len(ns) > 0 {
+			fmt.Fprintln(out, strings.Join(ns, "\t"))
+		}
+		fmt.Fprintln(out, strings.Join(vs, "\t"))
+	default:
+		l.text(x)
+	}
+
+	return nil
+}
+
+// forEachItem() compatible version of add()
+func (l *lister[T]) each(x T) (bool, error) {
+	return false, l.add(x)
+}
+
+func (l *lister[T]) done() error {
+	if outputTmpl != nil {
+		return nil
+	}
+
+	// always output a list, even if empty
+	xs := l.xs
+	if xs == nil {
+		xs = []T{}
+	}
+
+	switch outputFmt {
+	case "json":
+		s, err := json.MarshalIndent(xs, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", s)
+		return err
+	case "yaml":
+		// going through JSON allows to re-use the json tags
+		var ys interface{}
+		s, err := json.Marshal(xs)
+		if err == nil {
+			err = json.Unmarshal(s, &ys)
+		}
+		if err == nil {
+			s, err = yaml.Marshal(ys)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(s)
+		return err
+	}
+
+	return nil
+}
+
+// name of a struct field, as used in JSON
+func jsonName(f reflect.StructField) string {
+	n, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if n == "" {
+		return f.Name
+	}
+	return n
+}
+
+// flatten v in columns names and values: nested struct
+// fields are prefixed by their parent's name, slices are
+// comma-separated. Non-struct values have no name.
+func tsvFields(v reflect.Value, p string) ([]string, []string) {
+	var ns, vs []string
+
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct || v.Type() == reflect.TypeOf(time.Time{}) {
+		return nil, []string{tsvValue(v)}
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		n := jsonName(f)
+		if n == "-" {
+			continue
+		}
+		if p != "" {
+			n = p + "." + n
+		}
+		if f.Anonymous {
+			n = p
+		}
+
+		w := v.Field(i)
+		if w.Kind() == reflect.Struct && w.Type() != reflect.TypeOf(time.Time{}) {
+			xs, ys := tsvFields(w, n)
+			ns = append(ns, xs...)
+			vs = append(vs, ys...)
+		} else {
+			ns = append(ns, n)
+			vs = append(vs, tsvValue(w))
+		}
+	}
+
+	return ns, vs
+}
+
+// a single TSV value; tabs and newlines are escaped
+func tsvValue(v reflect.Value) string {
+	var s string
+
+	switch x := v.Interface().(type) {
+	case time.Time:
+		if !x.IsZero() {
+			s = x.Format(time.RFC3339)
+		}
+	default:
+		if v.Kind() == reflect.Slice {
+			xs := make([]string, 0, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				xs = append(xs, tsvValue(v.Index(i)))
+			}
+			s = strings.Join(xs, ",")
+		} else {
+			s = fmt.Sprint(x)
+		}
+	}
+
+	return strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n").Replace(s)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTsvFields(t *testing.T) {
+	f := func(x interface{}) ([]string, []string) {
+		return tsvFields(reflect.ValueOf(x), "")
+	}
+	doTests(t, []test{
+		{
+			"string",
+			f,
+			[]interface{}{"1.2.3.4"},
+			[]interface{}{[]string(nil), []string{"1.2.3.4"}},
+		},
+		{
+			"embedded and nested structs, slices",
+			f,
+			[]interface{}{VPS{
+				GetVPSName{"running", 1, GetVPSNameModel{1, 20, 2048}, "vps1"},
+				GetVPSNameIps{"1.2.3.4", "2001:db8::1"},
+				GetVPSNameDatacenter{"Gravelines", "gra", "fr"},
+			}},
+			[]interface{}{
+				[]string{
+					"state", "vcore", "model.vcore", "model.disk", "model.memory", "name",
+					"ips", "datacenter.longName", "datacenter.name", "datacenter.country",
+				},
+				[]string{
+					"running", "1", "1", "20", "2048", "vps1",
+					"1.2.3.4,2001:db8::1", "Gravelines", "gra", "fr",
+				},
+			},
+		},
+		{
+			"escaped values",
+			f,
+			[]interface{}{GetMeSSHKeyName{"a\tb\nc", "k", false}},
+			[]interface{}{
+				[]string{"key", "keyName", "default"},
+				[]string{"a\\tb\\nc", "k", "false"},
+			},
+		},
+	})
+}
+
+func TestLister(t *testing.T) {
+	f := func(o, tmpl string, xs []GetMeSSHKeyName) (string, error) {
+		var b bytes.Buffer
+		w, of, ot := out, outputFmt, outputTmpl
+		defer func() { out, outputFmt, outputTmpl = w, of, ot }()
+
+		out, outputTmpl = &b, nil
+		if err := setOutputFmt(o); err != nil {
+			return "", err
+		}
+		if tmpl != "" {
+			if err := setOutputTmpl(tmpl); err != nil {
+				return "", err
+			}
+		}
+		l := newLister(func(x GetMeSSHKeyName) {
+			b.WriteString(x.KeyName + "\n")
+		})
+		for _, x := range xs {
+			if err := l.add(x); err != nil {
+				return "", err
+			}
+		}
+		err := l.done()
+		return b.String(), err
+	}
+
+	xs := []GetMeSSHKeyName{{"ssh-ed25519 AAA", "a", true}, {"ssh-rsa BBB", "b", false}}
+
+	doTests(t, []test{
+		{
+			"text",
+			f,
+			[]interface{}{"text", "", xs},
+			[]interface{}{"a\nb\n", nil},
+		},
+		{
+			"empty JSON",
+			f,
+			[]interface{}{"json", "", []GetMeSSHKeyName{}},
+			[]interface{}{"[]\n", nil},
+		},
+		{
+			"JSON",
+			f,
+			[]interface{}{"json", "", xs[:1]},
+			[]interface{}{"[\n\t{\n\t\t\"key\": \"ssh-ed25519 AAA\",\n\t\t\"keyName\": \"a\",\n\t\t\"default\": true\n\t}\n]\n", nil},
+		},
+		{
+			"TSV",
+			f,
+			[]interface{}{"tsv", "", xs},
+			[]interface{}{"key\tkeyName\tdefault\nssh-ed25519 AAA\ta\ttrue\nssh-rsa BBB\tb\tfalse\n", nil},
+		},
+		{
+			"YAML",
+			f,
+			[]interface{}{"yaml", "", xs[1:]},
+			[]interface{}{"- default: false\n  key: ssh-rsa BBB\n  keyName: b\n", nil},
+		},
+		{
+			"template",
+			f,
+			[]interface{}{"json", "{{.KeyName}}={{.Default}}", xs},
+			[]interface{}{"a=true\nb=false\n", nil},
+		},
+	})
+}
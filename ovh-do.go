@@ -5,11 +5,16 @@ package main
 
 import (
 	"bytes"
-	//	"flag"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/ovh/go-ovh/ovh"
+	"gopkg.in/ini.v1"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -76,6 +81,16 @@ type GetMeApiCredentialId struct {
 // TODO: ALPHA API
 type DeleteMeApiCredentialId struct{}
 
+// https://api.ovh.com/console/#/auth/currentCredential~GET
+type GetAuthCurrentCredential GetMeApiCredentialId
+
+// ls-creds' items
+type Credential struct {
+	GetMeApiCredentialId
+	// application name, if known
+	Application string `json:"application"`
+}
+
 // https://api.ovh.com/console/#/vps~GET
 type GetVPS []string
 
@@ -104,6 +119,13 @@ type GetVPSNameDatacenter struct {
 	Country  string `json:"country"`
 }
 
+// ls-vps' items
+type VPS struct {
+	GetVPSName
+	Ips        GetVPSNameIps        `json:"ips"`
+	Datacenter GetVPSNameDatacenter `json:"datacenter"`
+}
+
 // https://api.ovh.com/console/#/vps/%7BserviceName%7D/getConsoleUrl~POST
 type PostInVPSNameGetConsole struct{}
 type PostOutVPSNameGetConsole string
@@ -151,13 +173,19 @@ type VPSTask struct {
 // https://api.ovh.com/console/#/vps/%7BserviceName%7D/rebuild~POST
 // TODO: Beta API
 type PostInVPSNameRebuild struct {
-	DoNotSendPassword bool   `json:"doNotSendPassword",omitempty`
+	DoNotSendPassword bool   `json:"doNotSendPassword,omitempty"`
 	ImageId           string `json:"imageId"`
 	InstallRTM        bool   `json:"installRTM,omitempty"`
 	SshKey            string `json:"sshKey,omitempty"`
 }
 type PostOutVPSNameRebuild VPSTask
 
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/start~POST
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/stop~POST
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/reboot~POST
+type PostInVPSNamePower struct{}
+type PostOutVPSNamePower VPSTask
+
 // https://api.ovh.com/console/#/vps/%7BserviceName%7D/tasks/%7Bid%7D~GET
 type GetVPSNameTasksId VPSTask
 
@@ -187,15 +215,68 @@ type GetDomainZoneZoneNameHistoryCreationDate struct {
 	CreationDate time.Time `json:"creationDate"`
 }
 
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/task/%7Bid%7D~GET
+type DomainZoneTask struct {
+	Comment      string    `json:"comment"`
+	CreationDate time.Time `json:"creationDate"`
+	DoneDate     time.Time `json:"doneDate"`
+	Function     string    `json:"function"`
+	Id           int       `json:"id"`
+	LastUpdate   time.Time `json:"lastUpdate"`
+	Status       string    `json:"status"`
+	TodoDate     time.Time `json:"todoDate"`
+}
+type GetDomainZoneZoneNameTaskId DomainZoneTask
+
 // https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/import~POST
-// TODO: implement
-type PostDomainZoneZoneNameExport struct {
+type PostInDomainZoneZoneNameImport struct {
 	ZoneFile string `json:"zoneFile"`
 }
+type PostOutDomainZoneZoneNameImport DomainZoneTask
+
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/record~GET
+type GetDomainZoneZoneNameRecord []int
+
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/record/%7Bid%7D~GET
+type GetDomainZoneZoneNameRecordId struct {
+	FieldType string `json:"fieldType"`
+	Id        int    `json:"id"`
+	SubDomain string `json:"subDomain"`
+	Target    string `json:"target"`
+	TTL       int    `json:"ttl"`
+	Zone      string `json:"zone"`
+}
+
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/record~POST
+type PostInDomainZoneZoneNameRecord struct {
+	FieldType string `json:"fieldType"`
+	SubDomain string `json:"subDomain,omitempty"`
+	Target    string `json:"target"`
+	TTL       int    `json:"ttl,omitempty"`
+}
+type PostOutDomainZoneZoneNameRecord GetDomainZoneZoneNameRecordId
+
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/record/%7Bid%7D~PUT
+//
+// NOTE: a nil TTL is left untouched, 0 is the zone's default.
+type PutInDomainZoneZoneNameRecordId struct {
+	SubDomain *string `json:"subDomain,omitempty"`
+	Target    string  `json:"target,omitempty"`
+	TTL       *int    `json:"ttl,omitempty"`
+}
+type PutOutDomainZoneZoneNameRecordId struct{}
+
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/record/%7Bid%7D~DELETE
+type DeleteDomainZoneZoneNameRecordId struct{}
+
+// https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/refresh~POST
+type PostInDomainZoneZoneNameRefresh struct{}
+type PostOutDomainZoneZoneNameRefresh struct{}
 
 // https://api.ovh.com/console/#/domain/zone/%7BzoneName%7D/history/%7BcreationDate%7D/restore~POST
 // TODO: Beta API
-// TODO: implement
+type PostInDomainZoneZoneNameHistoryCreationDateRestore struct{}
+type PostOutDomainZoneZoneNameHistoryCreationDateRestore DomainZoneTask
 
 // ----------------------------------------------------------------------
 // globals/constants
@@ -213,14 +294,18 @@ var preferedKeys = []string{
 // default OVH SSH key name
 var ovhKeyName = "ovh-do-key"
 
-// TODO: make this configurable [-t timeout]
+// Configurable via [ovh-do] in confFn, or global flags
+// (see loadConf() and parseGlobalFlags())
 var poolValidatedTimeout = 2 * time.Minute
 var poolRebuildTimeout = 5 * time.Minute
+var poolZoneTaskTimeout = 5 * time.Minute
+
+// Delay between two API calls while pooling
+var poolInterval = 5 * time.Second
 
 // Wait a little for the VPS to be up before running
 // resetKnownHosts(), and more generally, to attempt
 // ssh(1) connections. So far, this was enough.
-// TODO: make this configurable
 var waitVPSUp = 5 * time.Second
 
 var confFn = os.Getenv("HOME") + "/.ovh.conf"
@@ -246,32 +331,39 @@ func isValidated(c *ovh.Client) (bool, error) {
 // customer key for use in the client; hence, all (authenticated)
 // requests will now fail until the credential has been validated.
 func poolForValidated(c *ovh.Client) error {
-	a := time.Now().Add(poolValidatedTimeout)
-	for {
-		time.Sleep(5 * time.Second)
-		if time.Now().After(a) {
-			break
-		}
-
-		// TODO: Debug log maybe?
-		//		fmt.Println("Pooling...")
+	return watchTask(c, taskWatcher{
+		name:    "Consumer key validation",
+		timeout: poolValidatedTimeout,
+		get: func(c *ovh.Client) (taskStatus, error) {
+			ok, err := isValidated(c)
+			return taskStatus{
+				State:    "pendingValidation",
+				Progress: -1,
+				done:     ok,
+			}, err
+		},
+	})
+}
 
-		ok, err := isValidated(c)
-		if ok {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
+// request a new consumer key, with the current scope's rules;
+// r is an optional URL the user is redirected to once the key
+// is validated.
+//
+// NOTE: the key is registered in c, which won't be usable
+// until the key is validated.
+func requestKey(c *ovh.Client, r string) (*ovh.CkValidationState, error) {
+	rs, err := getScopeRules()
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("Waiting for credential validation timeout")
+	ck := c.NewCkRequestWithRedirection(r)
+	ck.AccessRules = rs
+	return ck.Do()
 }
 
 func requestNewKey(c *ovh.Client) (string, error) {
-	ck := c.NewCkRequest()
-	ck.AddRecursiveRules(ovh.ReadWrite, "/")
-	s, err := ck.Do()
+	s, err := requestKey(c, "")
 	if err != nil {
 		return "", err
 	}
@@ -287,41 +379,247 @@ func requestNewKey(c *ovh.Client) (string, error) {
 	return c.ConsumerKey, nil
 }
 
-// replace the customer_key in s, where s is a loaded .ini
-// file ($HOME/.ovh.conf's content)
-func replaceKey(s []byte, k string) []byte {
-	re := regexp.MustCompile("consumer_key=.*\n")
-	return re.ReplaceAll(s, []byte("consumer_key="+k+"\n"))
-}
-
-// edit the customer_key entry of the $HOME/.ovh.conf file
+// edit the customer_key entry of the $HOME/.ovh.conf file,
+// in the current profile's section; or store it with the
+// section's secret command, if any.
 func editNewKey(k string) error {
-	s, err := os.ReadFile(confFn)
+	f, err := ini.LooseLoad(confFn)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(confFn, replaceKey(s, k), 0644)
+	n := profile
+	if n == "" {
+		n = confEndpoint(f)
+	}
+	if s, err := f.GetSection(n); err == nil {
+		if ok, err := storeSecret(s, "consumer_key", k); ok || err != nil {
+			return err
+		}
+	}
+	return setConfKey(n, "consumer_key", k)
 }
 
 // remove all expired credentials
 func flushExpiredCredentials(c *ovh.Client) error {
-	var xs GetMeApiCredential
-	var d GetMeApiCredentialId
-	var e DeleteMeApiCredentialId
+	return rmCreds(c, credFilter{expired: true}, nil)
+}
 
-	if err := c.Get("/me/api/credential", &xs); err != nil {
+// rm-creds' selection criteria; all set criteria must match
+type credFilter struct {
+	expired bool
+	// application name or ID
+	app string
+	// minimum age
+	age time.Duration
+}
+
+func (f credFilter) empty() bool {
+	return !f.expired && f.app == "" && f.age == 0
+}
+
+func (f credFilter) match(x Credential, now time.Time) bool {
+	if f.expired && x.Status != "expired" {
+		return false
+	}
+	if f.app != "" && f.app != x.Application && f.app != strconv.Itoa(x.ApplicationId) {
+		return false
+	}
+	if f.age > 0 && x.Creation.After(now.Add(-f.age)) {
+		return false
+	}
+	return true
+}
+
+// parse a duration, allowing days (d) and weeks (w), e.g. 2w3d
+func parseAge(s string) (time.Duration, error) {
+	var d time.Duration
+	t := s
+	for _, u := range []struct {
+		c string
+		d time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		a, b, ok := strings.Cut(t, u.c)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("Invalid duration: '%s'", s)
+		}
+		d += time.Duration(n) * u.d
+		t = b
+	}
+	if t != "" {
+		e, err := time.ParseDuration(t)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration: '%s'", s)
+		}
+		d += e
+	}
+	return d, nil
+}
+
+// iterate on all credentials; application names are resolved
+// when possible (web console's applications can't be).
+func forEachCred(c *ovh.Client, f func(Credential) (bool, error)) error {
+	ns := map[int]string{}
+
+	return forEachItem(c,
+		"/me/api/credential",
+		func(y GetMeApiCredentialId) (bool, error) {
+			n, ok := ns[y.ApplicationId]
+			if !ok {
+				var b GetMeApiApplicationId
+				err := c.Get("/me/api/application/"+strconv.Itoa(y.ApplicationId), &b)
+				if err == nil {
+					n = b.Name
+				} else if serr, ok := err.(*ovh.APIError); !ok || serr.Code != http.StatusNotFound {
+					return true, err
+				}
+				ns[y.ApplicationId] = n
+			}
+			return f(Credential{y, n})
+		}, strconv.Itoa)
+}
+
+// a credential's date, "-" if unset
+func fmtCredDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func fmtCredApp(x Credential) string {
+	if x.Application == "" {
+		return strconv.Itoa(x.ApplicationId)
+	}
+	return x.Application
+}
+
+func fmtCredRules(x Credential) string {
+	xs := make([]string, 0, len(x.Rules))
+	for _, r := range x.Rules {
+		xs = append(xs, r.Method+" "+r.Path)
+	}
+	return strings.Join(xs, ", ")
+}
+
+// list credentials; if mine is set, only the non-expired
+// credentials of our application are listed.
+func lsCreds(c *ovh.Client, mine bool) error {
+	l := newLister(func(x Credential) {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			x.CredentialId, fmtCredApp(x), x.Status,
+			fmtCredDate(x.Creation), fmtCredDate(x.Expiration), fmtCredDate(x.LastUse),
+			strings.Join(x.AllowedIPs, ","), fmtCredRules(x))
+	})
+
+	if !mine {
+		if err := forEachCred(c, l.each); err != nil {
+			return err
+		}
+		return l.done()
+	}
+
+	xs, err := getNonExpiredCredential(c)
+	if err != nil {
 		return err
 	}
+	// all ours: same application
+	var b GetMeApiApplicationId
+	if len(xs) > 0 {
+		if err := c.Get("/me/api/application/"+strconv.Itoa(xs[0].ApplicationId), &b); err != nil {
+			return err
+		}
+	}
 	for _, x := range xs {
-		if err := c.Get("/me/api/credential/"+strconv.Itoa(x), &d); err != nil {
+		if err := l.add(Credential{*x, b.Name}); err != nil {
 			return err
 		}
-		if d.Status == "expired" {
-			if err := c.Delete("/me/api/credential/"+strconv.Itoa(x), &e); err != nil {
-				return err
-			}
+	}
+	return l.done()
+}
+
+func showCred(c *ovh.Client, i int) error {
+	var x Credential
+	if err := c.Get("/me/api/credential/"+strconv.Itoa(i), &x.GetMeApiCredentialId); err != nil {
+		return err
+	}
+
+	var b GetMeApiApplicationId
+	err := c.Get("/me/api/application/"+strconv.Itoa(x.ApplicationId), &b)
+	if err == nil {
+		x.Application = b.Name
+	} else if serr, ok := err.(*ovh.APIError); !ok || serr.Code != http.StatusNotFound {
+		return err
+	}
+
+	l := newLister(func(x Credential) {
+		fmt.Printf("id:          %d\n", x.CredentialId)
+		fmt.Printf("application: %s (%d)\n", x.Application, x.ApplicationId)
+		fmt.Printf("status:      %s\n", x.Status)
+		fmt.Printf("creation:    %s\n", fmtCredDate(x.Creation))
+		fmt.Printf("expiration:  %s\n", fmtCredDate(x.Expiration))
+		fmt.Printf("lastUse:     %s\n", fmtCredDate(x.LastUse))
+		fmt.Printf("allowedIPs:  %s\n", strings.Join(x.AllowedIPs, ", "))
+		fmt.Printf("rules:\n")
+		for _, r := range x.Rules {
+			fmt.Printf("  - %s %s\n", r.Method, r.Path)
+		}
+	})
+	if err := l.add(x); err != nil {
+		return err
+	}
+	return l.done()
+}
+
+func rmCred(c *ovh.Client, i int) error {
+	var e DeleteMeApiCredentialId
+	return c.Delete("/me/api/credential/"+strconv.Itoa(i), &e)
+}
+
+// remove the credentials is, and those matching f (if not
+// empty). The credential in use is only removed if explicitly
+// listed in is.
+func rmCreds(c *ovh.Client, f credFilter, is []int) error {
+	for _, i := range is {
+		if err := rmCred(c, i); err != nil {
+			return err
 		}
 	}
+	if f.empty() {
+		return nil
+	}
+
+	var y GetAuthCurrentCredential
+	if err := c.Get("/auth/currentCredential", &y); err != nil {
+		return err
+	}
+
+	// collect first: don't delete while iterating
+	var xs []Credential
+	now := time.Now()
+	err := forEachCred(c, func(x Credential) (bool, error) {
+		if f.match(x, now) {
+			xs = append(xs, x)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, x := range xs {
+		if x.CredentialId == y.CredentialId {
+			log.Printf("Skipping credential %d, currently in use\n", x.CredentialId)
+			continue
+		}
+		if err := rmCred(c, x.CredentialId); err != nil {
+			return err
+		}
+		log.Printf("Removed credential %d (%s, %s)\n", x.CredentialId, fmtCredApp(x), x.Status)
+	}
 
 	return nil
 }
@@ -368,12 +666,41 @@ func getNonExpiredCredential(c *ovh.Client) ([]*GetMeApiCredentialId, error) {
 	return ys, nil
 }
 
-// grab a working client, cleanup expired credentials
-func getClient() (*ovh.Client, error) {
-	c, err := ovh.NewDefaultClient()
+// a client whose consumer key may not be validated; for
+// the current profile, if any.
+func newClient() (*ovh.Client, error) {
+	var c *ovh.Client
+
+	f, err := ini.LooseLoad(confFn)
+	if err != nil {
+		return nil, fmt.Errorf("Loading %s: %s", confFn, err)
+	}
+
+	if n := profile; n != "" || usesSecrets(f.Section(confEndpoint(f))) {
+		if n == "" {
+			n = confEndpoint(f)
+		}
+		var p *Profile
+		if p, err = getProfile(f, n); err != nil {
+			return nil, err
+		}
+		c, err = newProfileClient(p)
+	} else {
+		c, err = ovh.NewDefaultClient()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("Creating new client: %s", err)
 	}
+	return c, nil
+}
+
+// grab a working client; a new customer key is requested
+// if the current one isn't validated.
+func getClient() (*ovh.Client, error) {
+	c, err := newClient()
+	if err != nil {
+		return nil, err
+	}
 	ok, err := isValidated(c)
 	if err != nil {
 		return nil, fmt.Errorf("Customer key validated: %s", err)
@@ -389,39 +716,33 @@ func getClient() (*ovh.Client, error) {
 		}
 	}
 
-	if err = flushExpiredCredentials(c); err != nil {
-		return nil, fmt.Errorf("Flushing expired credentials: %s", err)
-	}
-
 	return c, nil
 }
 
-func help(n int) {
-	fmt.Println("TODO")
-	os.Exit(n)
-}
-
 // XXX generic experimentation; perhaps they are better approaches
 // let's see where this goes.
 //
 // This is a bit clumsy so far, but works.
 type Item interface {
-	GetMeApiApplicationId | GetVPSName | GetMeSSHKeyName | GetVPSNameImagesAvailableId | GetDomainZoneZoneName | GetDomainZoneZoneNameHistoryCreationDate
+	GetMeApiApplicationId | GetMeApiCredentialId | GetVPSName | GetMeSSHKeyName | GetVPSNameImagesAvailableId | GetDomainZoneZoneName | GetDomainZoneZoneNameHistoryCreationDate | GetDomainZoneZoneNameRecordId | GetVPSNameTasksId
 }
 type ItemId interface{ string | int }
 
 func id[T any](x T) T { return x }
 
+// r may contain a query string (e.g. filters), which is
+// only used to list the items.
 func forEachItem[T Item, U ItemId](c *ovh.Client, r string,
 	f func(T) (bool, error), g func(U) string) error {
 	var xs []U
-	var y T
-	if err := c.Get(r, &xs); err != nil {
+	if err := cachedGet(context.Background(), c, r, &xs); err != nil {
 		return err
 	}
 
+	p, _, _ := strings.Cut(r, "?")
 	for _, x := range xs {
-		if err := c.Get(r+"/"+g(x), &y); err != nil {
+		var y T
+		if err := cachedGet(context.Background(), c, p+"/"+g(x), &y); err != nil {
 			return err
 		}
 		stop, err := f(y)
@@ -437,12 +758,10 @@ func forEachItem[T Item, U ItemId](c *ovh.Client, r string,
 }
 
 func lsApps(c *ovh.Client) error {
-	return forEachItem(c,
-		"/me/api/application",
-		func(y GetMeApiApplicationId) (bool, error) {
-			fmt.Printf("%s %d %s %s\n", y.Name, y.ApplicationId, y.Status, y.Description)
-			return false, nil
-		}, strconv.Itoa)
+	l := newLister(func(y GetMeApiApplicationId) {
+		fmt.Printf("%s %d %s %s\n", y.Name, y.ApplicationId, y.Status, y.Description)
+	})
+	return listItems(c, "/me/api/application", l, strconv.Itoa)
 }
 
 // NOTE: we assume a to either be an integer (ie. an ID) or
@@ -476,31 +795,128 @@ func rmApp(c *ovh.Client, a string) error {
 	return c.Delete("/me/api/application/"+strconv.Itoa(id), &z)
 }
 
-func lsVPS(c *ovh.Client) error {
-	return forEachItem(c,
-		"/vps",
-		func(y GetVPSName) (bool, error) {
-			var ips GetVPSNameIps
-			var dc GetVPSNameDatacenter
-			x := y.Name
+// ls-vps' selection criteria; all set criteria must match
+type vpsFilter struct {
+	state string
+	// datacenter name prefix (e.g. gra, sbg3)
+	dc   string
+	name *regexp.Regexp
+	ip   *net.IPNet
+}
 
-			if err := c.Get("/vps/"+x+"/ips", &ips); err != nil {
-				return true, err
-			}
-			if err := c.Get("/vps/"+x+"/datacenter", &dc); err != nil {
-				return true, err
-			}
-			fmt.Printf("%s:\n", x)
-			fmt.Printf("  state: %s\n", y.State)
-			fmt.Printf("  loc:   %s (%s)\n", dc.LongName, dc.Country)
-			fmt.Printf("  ips:\n")
-			for _, ip := range ips {
-				fmt.Printf("    - %s\n", ip)
+func (f vpsFilter) match(x VPS) bool {
+	if f.state != "" && !strings.EqualFold(f.state, x.State) {
+		return false
+	}
+	if f.dc != "" && !strings.HasPrefix(strings.ToLower(x.Datacenter.Name), strings.ToLower(f.dc)) {
+		return false
+	}
+	if f.name != nil && !f.name.MatchString(x.Name) {
+		return false
+	}
+	if f.ip != nil {
+		for _, s := range x.Ips {
+			s, _, _ = strings.Cut(s, "/")
+			if ip := net.ParseIP(s); ip != nil && f.ip.Contains(ip) {
+				return true
 			}
-			fmt.Printf("  disk:  %dG\n", y.Model.Disk)
-			fmt.Printf("  mem:   %dM\n", y.Model.Memory)
-			return false, nil
-		}, id[string])
+		}
+		return false
+	}
+	return true
+}
+
+// a VPS' details, IPs and datacenter
+func getVPS(ctx context.Context, c *ovh.Client, v string) (*VPS, error) {
+	var x VPS
+
+	if err := getRetry(ctx, c, "/vps/"+v, &x.GetVPSName); err != nil {
+		return nil, err
+	}
+	if err := getRetry(ctx, c, "/vps/"+v+"/ips", &x.Ips); err != nil {
+		return nil, err
+	}
+	if err := getRetry(ctx, c, "/vps/"+v+"/datacenter", &x.Datacenter); err != nil {
+		return nil, err
+	}
+	return &x, nil
+}
+
+// fetch the VPSs named vs, with at most j concurrent
+// fetches; results are in vs' order. With k, failing VPSs
+// are left nil, and their errors returned as an itemErrors.
+func getVPSs(c *ovh.Client, vs []string, j int, k bool) ([]*VPS, error) {
+	xs := make([]*VPS, len(vs))
+	errs := make([]error, len(vs))
+
+	runJobs(context.Background(), len(vs), j, func(ctx context.Context, i int) {
+		xs[i], errs[i] = getVPS(ctx, cloneClient(c), vs[i])
+	})
+
+	var es itemErrors
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s: %s", vs[i], err)
+		if !k {
+			return nil, err
+		}
+		es = append(es, err)
+	}
+	if len(es) > 0 {
+		return xs, es
+	}
+	return xs, nil
+}
+
+// list VPSs matching f, sorted by name; j VPSs are
+// fetched concurrently. Names are filtered before fetching.
+func lsVPS(c *ovh.Client, f vpsFilter, j int) error {
+	var vs, ws []string
+
+	l := newLister(func(y VPS) {
+		fmt.Printf("%s:\n", y.Name)
+		fmt.Printf("  state: %s\n", y.State)
+		fmt.Printf("  loc:   %s (%s)\n", y.Datacenter.LongName, y.Datacenter.Country)
+		fmt.Printf("  ips:\n")
+		for _, ip := range y.Ips {
+			fmt.Printf("    - %s\n", ip)
+		}
+		fmt.Printf("  disk:  %dG\n", y.Model.Disk)
+		fmt.Printf("  mem:   %dM\n", y.Model.Memory)
+	})
+
+	if err := c.Get("/vps", &vs); err != nil {
+		return err
+	}
+	sort.Strings(vs)
+	for _, v := range vs {
+		if f.name == nil || f.name.MatchString(v) {
+			ws = append(ws, v)
+		}
+	}
+
+	var es itemErrors
+	xs, err := getVPSs(c, ws, j, keepGoing)
+	if err != nil && !errors.As(err, &es) {
+		return err
+	}
+	for _, x := range xs {
+		if x == nil || !f.match(*x) {
+			continue
+		}
+		if err := l.add(*x); err != nil {
+			return err
+		}
+	}
+	if err := l.done(); err != nil {
+		return err
+	}
+	if len(es) > 0 {
+		return es
+	}
+	return nil
 }
 
 func getConsole(c *ovh.Client, v string) error {
@@ -535,10 +951,13 @@ func foreachIPs(c *ovh.Client, v string, f func(string) error) error {
 }
 
 func lsIPs(c *ovh.Client, v string) error {
-	return foreachIPs(c, v, func(x string) error {
+	l := newLister(func(x string) {
 		fmt.Println(x)
-		return nil
 	})
+	if err := foreachIPs(c, v, l.add); err != nil {
+		return err
+	}
+	return l.done()
 }
 
 func removeKnownHosts(ip string) error {
@@ -600,12 +1019,10 @@ func resetKnownHosts(c *ovh.Client, v string) error {
 }
 
 func lsKeys(c *ovh.Client) error {
-	return forEachItem(c,
-		"/me/sshKey",
-		func(y GetMeSSHKeyName) (bool, error) {
-			fmt.Printf("%s %s\n", y.KeyName, y.Key)
-			return false, nil
-		}, id[string])
+	l := newLister(func(y GetMeSSHKeyName) {
+		fmt.Printf("%s %s\n", y.KeyName, y.Key)
+	})
+	return listItems(c, "/me/sshKey", l, id[string])
 }
 
 func rmKey(c *ovh.Client, n string) error {
@@ -627,11 +1044,10 @@ func forEachImgs(c *ovh.Client, v string,
 }
 
 func lsImgs(c *ovh.Client, v string) error {
-	return forEachImgs(c, v,
-		func(y GetVPSNameImagesAvailableId) (bool, error) {
-			fmt.Printf("%s\t%s\n", y.Name, y.Id)
-			return false, nil
-		})
+	l := newLister(func(y GetVPSNameImagesAvailableId) {
+		fmt.Printf("%s\t%s\n", y.Name, y.Id)
+	})
+	return listItems(c, "/vps/"+v+"/images/available", l, id[string])
 }
 
 func splitImgName(s string) (string, float64, string, error) {
@@ -708,6 +1124,17 @@ func readSSHKey() (string, error) {
 	return "", fmt.Errorf("No SSH key found!")
 }
 
+// s is either a path to a SSH public key, or the key itself
+func readKeyArg(s string) (string, error) {
+	k, err := os.ReadFile(s)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	} else if err == nil {
+		return strings.TrimSuffix(string(k), "\n"), nil
+	}
+	return s, nil
+}
+
 // e.g. f4b12e37-4241-4301-aadf-85ae34cdd6a9
 func isImgId(s string) bool {
 	h := "[0-9a-fA-F]"
@@ -715,54 +1142,121 @@ func isImgId(s string) bool {
 	return regexp.MustCompile(r).MatchString(s)
 }
 
-func poolTask(c *ovh.Client, v string, i int) error {
-	done := map[string]bool{
-		"cancelled": true,
-		"done":      true,
-		"error":     true,
+// start, stop or reboot (op) v, and wait for the
+// operation to complete.
+func powerVPS(c *ovh.Client, v, op string) error {
+	var x PostInVPSNamePower
+	var y PostOutVPSNamePower
+	if err := c.Post("/vps/"+v+"/"+op, &x, &y); err != nil {
+		return err
 	}
-	a := time.Now().Add(poolRebuildTimeout)
-	for {
-		time.Sleep(5 * time.Second)
-		if time.Now().After(a) {
-			break
-		}
+	return poolTask(c, v, y.Id)
+}
 
-		var x GetVPSNameTasksId
+// stop and start v, instead of a reboot
+func hardRebootVPS(c *ovh.Client, v string) error {
+	if err := powerVPS(c, v, "stop"); err != nil {
+		return err
+	}
+	return powerVPS(c, v, "start")
+}
 
-		if err := c.Get("/vps/"+v+"/tasks/"+strconv.Itoa(i), &x); err != nil {
-			return err
-		}
-		if _, ok := done[x.State]; ok {
-			return nil
-		}
+// whether an sshd answers on a (host:port)
+func isSSHUp(a string) bool {
+	conn, err := net.DialTimeout("tcp", a, poolInterval)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
 
-		fmt.Printf("%d%%\n", x.Progress)
+	conn.SetReadDeadline(time.Now().Add(poolInterval))
+	b := make([]byte, 4)
+	_, err = io.ReadFull(conn, b)
+	return err == nil && string(b) == "SSH-"
+}
+
+// wait until v's sshd answers, on its first IPv4
+func waitSSH(c *ovh.Client, v string) error {
+	ips, err := getIPs(c, v)
+	if err != nil {
+		return err
+	}
+	v4, _, err := splitIPs(*ips)
+	if err != nil {
+		return err
+	}
+	if len(v4) == 0 {
+		return fmt.Errorf("%s: no IPv4", v)
 	}
 
-	return fmt.Errorf("Rebuild pooling timeout")
+	a := net.JoinHostPort(v4[0], "22")
+	t := time.Now().Add(poolRebuildTimeout)
+	for time.Now().Before(t) {
+		if isSSHUp(a) {
+			return nil
+		}
+		time.Sleep(poolInterval)
+	}
+	return fmt.Errorf("%s: sshd pooling timeout", v)
 }
 
-func rebuildPoolResetKnownHosts(c *ovh.Client, v, i, kn string) error {
+// n is the FQDN v's IPs are to be registered under, if any;
+// only used to tell how to resume the rebuild.
+func rebuildPoolResetKnownHosts(c *ovh.Client, v, i, kn, n string) error {
 	x := PostInVPSNameRebuild{true, i, false, kn}
 	var y PostOutVPSNameRebuild
 	if err := c.Post("/vps/"+v+"/rebuild", &x, &y); err != nil {
 		return err
 	}
-	if err := poolTask(c, v, y.Id); err != nil {
+	log.Printf("Waiting for VPS task %d (resume with: %s)\n", y.Id, waitTaskCmd(v, y.Id, n))
+	if err := watchTask(c, vpsTask(v, y.Id)); err != nil {
 		return err
 	}
 	time.Sleep(waitVPSUp)
 	return resetKnownHosts(c, v)
 }
 
+// rebuild v with the image i (see getMatchingImg()) and the
+// SSH key kn; if n is set, register v's IPs under this FQDN.
+// n's zone is resolved before rebuilding.
+func rebuild(c *ovh.Client, v, i, kn, n string, snap bool) error {
+	var z, sd string
+	if n != "" {
+		var err error
+		if z, sd, err = findZone(c, n); err != nil {
+			return err
+		}
+	}
+	if !isImgId(i) {
+		var err error
+		var in string
+		i, in, err = getMatchingImg(c, v, i)
+		if err != nil {
+			return err
+		}
+		log.Printf("Installing %s (%s) to %s; key=%s\n", in, i, v, kn)
+	}
+	if snap {
+		log.Printf("Snapshotting %s\n", v)
+		d := "ovh-do: before rebuild, " + time.Now().Format(time.RFC3339)
+		if err := createSnapshot(c, v, d); err != nil {
+			return fmt.Errorf("Snapshot before rebuild: %s", err)
+		}
+	}
+	if err := rebuildPoolResetKnownHosts(c, v, i, kn, n); err != nil {
+		return err
+	}
+	if n != "" {
+		return registerVPS(c, v, z, sd)
+	}
+	return nil
+}
+
 func lsZones(c *ovh.Client) error {
-	return forEachItem(c,
-		"/domain/zone",
-		func(y GetDomainZoneZoneName) (bool, error) {
-			fmt.Printf("%-30s %-30s %s\n", y.Name, y.LastUpdate, strings.Join(y.NameServers, ", "))
-			return false, nil
-		}, id[string])
+	l := newLister(func(y GetDomainZoneZoneName) {
+		fmt.Printf("%-30s %-30s %s\n", y.Name, y.LastUpdate, strings.Join(y.NameServers, ", "))
+	})
+	return listItems(c, "/domain/zone", l, id[string])
 }
 
 func getZone(c *ovh.Client, z string) error {
@@ -774,171 +1268,438 @@ func getZone(c *ovh.Client, z string) error {
 	return nil
 }
 
-func lsZoneBackups(c *ovh.Client, z string) error {
-	return forEachItem(c,
-		"/domain/zone/"+z+"/history",
-		func(y GetDomainZoneZoneNameHistoryCreationDate) (bool, error) {
-			fmt.Printf("%-30s %s\n", y.CreationDate, y.ZoneFileUrl)
-			return false, nil
-		}, id[string])
+// read a zone file from fn, or from stdin if fn is "-"
+func readZoneFile(fn string) (string, error) {
+	var s []byte
+	var err error
+	if fn == "-" {
+		s, err = io.ReadAll(os.Stdin)
+	} else {
+		s, err = os.ReadFile(fn)
+	}
+	return string(s), err
 }
 
-func main() {
-	c, err := getClient()
+// import the zone file fn ("-" for stdin) as z's new content
+func putZone(c *ovh.Client, z, fn string) error {
+	s, err := readZoneFile(fn)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	x := PostInDomainZoneZoneNameImport{s}
+	var y PostOutDomainZoneZoneNameImport
+	if err := c.Post("/domain/zone/"+z+"/import", &x, &y); err != nil {
+		return err
 	}
+	if err := poolZoneTask(c, z, y.Id); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s imported\n", z)
+	return nil
+}
 
-	// XXX so far, this is useless, remove
-	// ls-imgs is boilerplate free
-	//	rmkeysCmd := flag.NewFlagSet("rm-keys", flag.ExitOnError)
+func lsZoneBackups(c *ovh.Client, z string) error {
+	l := newLister(func(y GetDomainZoneZoneNameHistoryCreationDate) {
+		fmt.Printf("%-30s %s\n", y.CreationDate, y.ZoneFileUrl)
+	})
+	return listItems(c, "/domain/zone/"+z+"/history", l, id[string])
+}
 
-	if len(os.Args) < 2 {
-		help(1)
+// list z's history creation dates, most recent first
+func getZoneHistory(c *ovh.Client, z string) ([]string, error) {
+	var xs GetDomainZoneZoneNameHistory
+	if err := c.Get("/domain/zone/"+z+"/history", &xs); err != nil {
+		return nil, err
 	}
+	sortBackupDates(xs)
+	return xs, nil
+}
 
-	switch os.Args[1] {
-	case "ls-apps":
-		if err = lsApps(c); err != nil {
-			log.Fatal(err)
-		}
-	case "rm-apps":
-		for i := 2; i < len(os.Args); i++ {
-			if err = rmApp(c, os.Args[i]); err != nil {
-				log.Fatal(err)
-			}
-		}
-	case "ls-vps":
-		if err = lsVPS(c); err != nil {
-			log.Fatal(err)
-		}
-	case "ls-keys":
-		if err = lsKeys(c); err != nil {
-			log.Fatal(err)
-		}
-	case "ls-imgs":
-		if len(os.Args) <= 2 {
-			help(1)
-		}
-		if err := lsImgs(c, os.Args[2]); err != nil {
-			log.Fatal(err)
-		}
-	case "ls-img":
-		if len(os.Args) <= 3 {
-			help(1)
-		}
-		id, name, err := getMatchingImg(c, os.Args[2], os.Args[3])
+// sort creation dates, most recent first. Unparsable dates
+// are compared as strings.
+func sortBackupDates(xs []string) {
+	sort.SliceStable(xs, func(i, j int) bool {
+		a, err := time.Parse(time.RFC3339, xs[i])
 		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Printf("%s\t%s\n", name, id)
-	case "rebuild":
-		if len(os.Args) <= 3 {
-			help(1)
-		}
-		v := os.Args[2]
-		i := os.Args[3]
-		if !isImgId(i) {
-			var err error
-			var in string
-			i, in, err = getMatchingImg(c, v, i)
-			if err != nil {
-				log.Fatal(err)
-			}
-			log.Printf("Installing %s (%s) to %s\n", in, i, v)
+			return xs[i] > xs[j]
 		}
-		kn := ovhKeyName
-		if len(os.Args) > 4 {
-			kn = os.Args[4]
+		b, err := time.Parse(time.RFC3339, xs[j])
+		if err != nil {
+			return xs[i] > xs[j]
 		}
-		if err := rebuildPoolResetKnownHosts(c, v, i, kn); err != nil {
-			log.Fatal(err)
+		return a.After(b)
+	})
+}
+
+// select a creation date from xs (most recent first), where s is
+// either "latest", an index in xs (0 being the latest), or a
+// creation date.
+func pickBackup(xs []string, s string) (string, error) {
+	if s == "latest" {
+		s = "0"
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n >= len(xs) {
+			return "", fmt.Errorf("No backup #%d (%d available)", n, len(xs))
 		}
-	// shortcut
-	case "rebuild-debian":
-		if len(os.Args) <= 2 {
-			help(1)
+		return xs[n], nil
+	}
+	for _, x := range xs {
+		if x == s {
+			return x, nil
 		}
-		v := os.Args[2]
-		i, in, err := getMatchingImg(c, v, "Debian")
+	}
+	return "", fmt.Errorf("No backup created at %s", s)
+}
+
+// whether s may select a backup (see pickBackup()): "latest",
+// an index, or a creation date.
+func isBackupSelector(s string) bool {
+	if _, err := strconv.Atoi(s); err == nil || s == "latest" {
+		return true
+	}
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
+
+// restore z to the backup selected by s (see pickBackup())
+func restoreZone(c *ovh.Client, z, s string) error {
+	xs, err := getZoneHistory(c, z)
+	if err != nil {
+		return err
+	}
+	d, err := pickBackup(xs, s)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Restoring %s to %s\n", z, d)
+
+	var x PostInDomainZoneZoneNameHistoryCreationDateRestore
+	var y PostOutDomainZoneZoneNameHistoryCreationDateRestore
+	if err := c.Post("/domain/zone/"+z+"/history/"+d+"/restore", &x, &y); err != nil {
+		return err
+	}
+	if err := poolZoneTask(c, z, y.Id); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s restored to %s\n", z, d)
+	return nil
+}
+
+// fetch the zone file of z's backup created at d
+func getZoneBackup(c *ovh.Client, z, d string) (string, error) {
+	var x GetDomainZoneZoneNameHistoryCreationDate
+	if err := c.Get("/domain/zone/"+z+"/history/"+d, &x); err != nil {
+		return "", err
+	}
+
+	r, err := http.Get(x.ZoneFileUrl)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Fetching %s: %s", x.ZoneFileUrl, r.Status)
+	}
+
+	s, err := io.ReadAll(r.Body)
+	return string(s), err
+}
+
+// diff the live zone z against either a local file ("-" for
+// stdin) or a backup (see pickBackup()); defaults to the
+// latest backup. Files have precedence over backups.
+func diffZone(c *ovh.Client, z, s string) error {
+	if s == "" {
+		s = "latest"
+	}
+
+	var b string
+	_, err := os.Stat(s)
+	if s != "-" && err != nil && !isBackupSelector(s) {
+		return err
+	}
+
+	var x GetDomainZoneZoneNameExport
+	if err := c.Get("/domain/zone/"+z+"/export", &x); err != nil {
+		return err
+	}
+
+	if s == "-" || err == nil {
+		b, err = readZoneFile(s)
+	} else {
+		var xs []string
+		xs, err = getZoneHistory(c, z)
 		if err != nil {
-			log.Fatal(err)
-		}
-		kn := ovhKeyName
-		if len(os.Args) > 3 {
-			kn = os.Args[3]
+			return err
 		}
-		log.Printf("Installing %s (%s) to %s; key=%s\n", in, i, v, kn)
-		if err := rebuildPoolResetKnownHosts(c, v, i, kn); err != nil {
-			log.Fatal(err)
+		if s, err = pickBackup(xs, s); err != nil {
+			return err
 		}
-	case "rm-keys":
-		for i := 2; i < len(os.Args); i++ {
-			if err = rmKey(c, os.Args[i]); err != nil {
-				log.Fatal(err)
+		b, err = getZoneBackup(c, z, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	xs, err := normalizeZone(string(x), z)
+	if err != nil {
+		return fmt.Errorf("%s (live): %s", z, err)
+	}
+	ys, err := normalizeZone(b, z)
+	if err != nil {
+		return fmt.Errorf("%s: %s", s, err)
+	}
+
+	fmt.Print(unifiedDiff(xs, ys, z+" (live)", s, 3))
+	return nil
+}
+
+// OVH uses an empty sub-domain for the zone's apex
+func subDomain(s string) string {
+	if s == "@" {
+		return ""
+	}
+	return s
+}
+
+func refreshZone(c *ovh.Client, z string) error {
+	var x PostInDomainZoneZoneNameRefresh
+	var y PostOutDomainZoneZoneNameRefresh
+	uncache(c, "/domain/zone/"+z)
+	return c.Post("/domain/zone/"+z+"/refresh", &x, &y)
+}
+
+// iterate on z's records, optionally restricted to
+// a given type t and sub-domain s.
+func forEachRecord(c *ovh.Client, z, t, s string,
+	f func(GetDomainZoneZoneNameRecordId) (bool, error)) error {
+	q := url.Values{}
+	if t != "" {
+		q.Set("fieldType", strings.ToUpper(t))
+	}
+	// an empty subDomain would be ignored
+	if s != "" && s != "@" {
+		q.Set("subDomain", s)
+	}
+	r := "/domain/zone/" + z + "/record"
+	if len(q) > 0 {
+		r += "?" + q.Encode()
+	}
+
+	return forEachItem(c, r,
+		func(y GetDomainZoneZoneNameRecordId) (bool, error) {
+			if s == "@" && y.SubDomain != "" {
+				return false, nil
 			}
-		}
-	case "add-key":
-		kn := ovhKeyName
-		var k string
+			return f(y)
+		}, strconv.Itoa)
+}
+
+func lsRecords(c *ovh.Client, z, t, s string) error {
+	l := newLister(func(y GetDomainZoneZoneNameRecordId) {
+		fmt.Printf("%d\t%s\t%d\t%s\t%s\n", y.Id, fmtSubDomain(y.SubDomain), y.TTL, y.FieldType, y.Target)
+	})
+	if err := forEachRecord(c, z, t, s, l.each); err != nil {
+		return err
+	}
+	return l.done()
+}
+
+func addRecord(c *ovh.Client, z, t, s, v string, ttl int) (int, error) {
+	x := PostInDomainZoneZoneNameRecord{strings.ToUpper(t), subDomain(s), v, ttl}
+	var y PostOutDomainZoneZoneNameRecord
+	err := c.Post("/domain/zone/"+z+"/record", &x, &y)
+	return y.Id, err
+}
+
+// update record i's target v and/or TTL; empty v and nil
+// ttl are left untouched.
+func setRecord(c *ovh.Client, z string, i int, v string, ttl *int) error {
+	x := PutInDomainZoneZoneNameRecordId{nil, v, ttl}
+	var y PutOutDomainZoneZoneNameRecordId
+	return c.Put("/domain/zone/"+z+"/record/"+strconv.Itoa(i), &x, &y)
+}
+
+func rmRecord(c *ovh.Client, z string, i int) error {
+	var x DeleteDomainZoneZoneNameRecordId
+	return c.Delete("/domain/zone/"+z+"/record/"+strconv.Itoa(i), &x)
+}
+
+func getRecords(c *ovh.Client, z, t, s string) ([]GetDomainZoneZoneNameRecordId, error) {
+	var xs []GetDomainZoneZoneNameRecordId
+	err := forEachRecord(c, z, t, s,
+		func(y GetDomainZoneZoneNameRecordId) (bool, error) {
+			xs = append(xs, y)
+			return false, nil
+		})
+	return xs, err
+}
+
+// apply the changes xs (see planZone()) to z, and refresh it
+func applyZoneChanges(c *ovh.Client, z string, xs []ZoneChange) error {
+	if len(xs) == 0 {
+		return nil
+	}
+	for _, x := range xs {
 		var err error
-		if len(os.Args) <= 2 {
-			if k, err = readSSHKey(); err != nil {
-				log.Fatal(err)
-			}
-		}
-		if len(os.Args) >= 3 {
-			kn = os.Args[2]
-		}
-		if len(os.Args) >= 4 {
-			s, err := os.ReadFile(os.Args[3])
-			if err != nil && !os.IsNotExist(err) {
-				log.Fatal(err)
-			} else if err == nil {
-				k = strings.TrimSuffix(string(s), "\n")
-			} else {
-				k = os.Args[3]
-			}
+		switch x.Op {
+		case '+':
+			_, err = addRecord(c, z, x.New.FieldType, x.New.SubDomain, x.New.Target, x.New.TTL)
+		case '~':
+			ttl := x.New.TTL
+			err = setRecord(c, z, x.Old.Id, x.New.Target, &ttl)
+		case '-':
+			err = rmRecord(c, z, x.Old.Id)
 		}
-		fmt.Println(kn, k)
-		if err = addKey(c, kn, k); err != nil {
-			log.Fatal(err)
+		if err != nil {
+			return fmt.Errorf("%s: %s", x, err)
 		}
-	case "get-console":
-		if len(os.Args) < 3 {
-			help(1)
+	}
+	return refreshZone(c, z)
+}
+
+// print the changes for z to match the spec stored in fn;
+// apply them if apply is set.
+func syncZone(c *ovh.Client, z, fn string, apply, prune bool) error {
+	x, err := loadZoneSpec(fn)
+	if err != nil {
+		return err
+	}
+	ys, err := getRecords(c, z, "", "")
+	if err != nil {
+		return err
+	}
+
+	xs := planZone(ys, x.records(), prune)
+	if len(xs) == 0 {
+		log.Printf("%s: up to date\n", z)
+		return nil
+	}
+	for _, x := range xs {
+		fmt.Println(x)
+	}
+
+	if !apply {
+		return nil
+	}
+	return applyZoneChanges(c, z, xs)
+}
+
+// find, among zones xs, the one hosting the FQDN n; returns
+// the zone and n's sub-domain within it.
+func pickZone(xs []string, n string) (string, string, error) {
+	n = strings.TrimSuffix(strings.ToLower(n), ".")
+	z := ""
+	for _, x := range xs {
+		x = strings.ToLower(x)
+		if (n == x || strings.HasSuffix(n, "."+x)) && len(x) > len(z) {
+			z = x
 		}
-		if err = getConsole(c, os.Args[2]); err != nil {
-			log.Fatal(err)
+	}
+	if z == "" {
+		return "", "", fmt.Errorf("No zone found for %s", n)
+	}
+	return z, strings.TrimSuffix(strings.TrimSuffix(n, z), "."), nil
+}
+
+// split IPv4 from IPv6 addresses; CIDR suffixes are removed.
+func splitIPs(xs []string) ([]string, []string, error) {
+	var v4, v6 []string
+	for _, x := range xs {
+		y, _, _ := strings.Cut(x, "/")
+		ip := net.ParseIP(y)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("Invalid IP: '%s'", x)
 		}
-	case "ls-ips":
-		if len(os.Args) < 3 {
-			help(1)
+		if ip.To4() != nil {
+			v4 = append(v4, y)
+		} else {
+			v6 = append(v6, y)
 		}
-		if err = lsIPs(c, os.Args[2]); err != nil {
-			log.Fatal(err)
+	}
+	return v4, v6, nil
+}
+
+// parse a CIDR; a single IP is a CIDR with a full mask
+func parseCIDR(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		n := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, n = ip.To4(), 8*net.IPv4len
 		}
-	case "ls-zones":
-		if err = lsZones(c); err != nil {
-			log.Fatal(err)
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(n, n)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CIDR: '%s'", s)
+	}
+	return n, nil
+}
+
+// the zone holding the FQDN n, and n's sub-domain in it
+// (see pickZone())
+func findZone(c *ovh.Client, n string) (string, string, error) {
+	var zs GetDomainZone
+	if err := c.Get("/domain/zone", &zs); err != nil {
+		return "", "", err
+	}
+	return pickZone(zs, n)
+}
+
+// make v's IPs reachable via the sub-domain sd of the zone z
+// (see findZone()): its A/AAAA records are updated to match
+// v's IPs. Existing TTLs are kept.
+func registerVPS(c *ovh.Client, v, z, sd string) error {
+	ips, err := getIPs(c, v)
+	if err != nil {
+		return err
+	}
+	v4, v6, err := splitIPs(*ips)
+	if err != nil {
+		return err
+	}
+
+	var xs []ZoneChange
+	for _, t := range []string{"A", "AAAA"} {
+		ips := v4
+		if t == "AAAA" {
+			ips = v6
 		}
-	case "get-zone":
-		if len(os.Args) < 3 {
-			help(1)
+		if len(ips) == 0 {
+			continue
 		}
-		if err = getZone(c, os.Args[2]); err != nil {
-			log.Fatal(err)
+		ys, err := getRecords(c, z, t, fmtSubDomain(sd))
+		if err != nil {
+			return err
 		}
-	case "ls-zone-backups":
-		if len(os.Args) < 3 {
-			help(1)
+		ttl := 0
+		if len(ys) > 0 {
+			ttl = ys[0].TTL
 		}
-		if err = lsZoneBackups(c, os.Args[2]); err != nil {
-			log.Fatal(err)
+		var ws []GetDomainZoneZoneNameRecordId
+		for _, ip := range ips {
+			ws = append(ws, GetDomainZoneZoneNameRecordId{
+				FieldType: t, SubDomain: sd, Target: ip, TTL: ttl,
+			})
 		}
-	case "help":
-		help(0)
-	default:
-		help(1)
+		xs = append(xs, planZone(ys, ws, true)...)
+	}
+
+	for _, x := range xs {
+		log.Println(x)
+	}
+	return applyZoneChanges(c, z, xs)
+}
+
+func main() {
+	if err := loadConf(); err != nil {
+		log.Fatal(err)
 	}
+	runCmd(parseGlobalFlags())
 }
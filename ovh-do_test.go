@@ -1,8 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"net"
+	"regexp"
 	"testing"
+	"time"
 )
 
 func TestSplitImgName(t *testing.T) {
@@ -80,3 +85,375 @@ func TestIsImgId(t *testing.T) {
 		},
 	})
 }
+
+func TestPickZoneBackup(t *testing.T) {
+	xs := []string{
+		"2023-03-01T10:00:00+01:00",
+		"2023-02-01T10:00:00+01:00",
+		"2023-01-01T10:00:00+01:00",
+	}
+	doTests(t, []test{
+		{
+			"latest",
+			pickBackup,
+			[]interface{}{xs, "latest"},
+			[]interface{}{xs[0], nil},
+		},
+		{
+			"by index",
+			pickBackup,
+			[]interface{}{xs, "2"},
+			[]interface{}{xs[2], nil},
+		},
+		{
+			"index out of range",
+			pickBackup,
+			[]interface{}{xs, "3"},
+			[]interface{}{"", fmt.Errorf("No backup #3 (3 available)")},
+		},
+		{
+			"by creation date",
+			pickBackup,
+			[]interface{}{xs, "2023-02-01T10:00:00+01:00"},
+			[]interface{}{xs[1], nil},
+		},
+		{
+			"unknown creation date",
+			pickBackup,
+			[]interface{}{xs, "2022-02-01T10:00:00+01:00"},
+			[]interface{}{"", fmt.Errorf("No backup created at 2022-02-01T10:00:00+01:00")},
+		},
+		{
+			"no backups",
+			pickBackup,
+			[]interface{}{[]string{}, "latest"},
+			[]interface{}{"", fmt.Errorf("No backup #0 (0 available)")},
+		},
+	})
+}
+
+func TestIsBackupSelector(t *testing.T) {
+	doTests(t, []test{
+		{
+			"latest",
+			isBackupSelector,
+			[]interface{}{"latest"},
+			[]interface{}{true},
+		},
+		{
+			"index",
+			isBackupSelector,
+			[]interface{}{"2"},
+			[]interface{}{true},
+		},
+		{
+			"date",
+			isBackupSelector,
+			[]interface{}{"2023-02-01T10:00:00+01:00"},
+			[]interface{}{true},
+		},
+		{
+			"file name",
+			isBackupSelector,
+			[]interface{}{"example.com.zone"},
+			[]interface{}{false},
+		},
+	})
+}
+
+func TestParseArgs(t *testing.T) {
+	f := func(xs []string) ([]string, string, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		v := fs.String("t", "", "")
+		as, err := parseArgs(fs, xs)
+		return as, *v, err
+	}
+	doTests(t, []test{
+		{
+			"no flags",
+			f,
+			[]interface{}{[]string{"a", "b"}},
+			[]interface{}{[]string{"a", "b"}, "", nil},
+		},
+		{
+			"intermixed flags",
+			f,
+			[]interface{}{[]string{"a", "-t", "A", "b"}},
+			[]interface{}{[]string{"a", "b"}, "A", nil},
+		},
+		{
+			"-- stops flag parsing",
+			f,
+			[]interface{}{[]string{"a", "--", "-t", "b"}},
+			[]interface{}{[]string{"a", "-t", "b"}, "", nil},
+		},
+		{
+			"unknown flag",
+			f,
+			[]interface{}{[]string{"a", "-x"}},
+			[]interface{}{[]string(nil), "", fmt.Errorf("flag provided but not defined: -x")},
+		},
+	})
+}
+
+func TestPickZone(t *testing.T) {
+	zs := []string{"example.com", "sub.example.com", "example.org"}
+	doTests(t, []test{
+		{
+			"apex",
+			pickZone,
+			[]interface{}{zs, "example.com."},
+			[]interface{}{"example.com", "", nil},
+		},
+		{
+			"longest zone wins",
+			pickZone,
+			[]interface{}{zs, "www.Sub.example.com"},
+			[]interface{}{"sub.example.com", "www", nil},
+		},
+		{
+			"no partial label match",
+			pickZone,
+			[]interface{}{zs, "notexample.org"},
+			[]interface{}{"", "", fmt.Errorf("No zone found for notexample.org")},
+		},
+	})
+}
+
+func TestSplitIPs(t *testing.T) {
+	doTests(t, []test{
+		{
+			"mixed",
+			splitIPs,
+			[]interface{}{[]string{"1.2.3.4", "2001:db8::1/64", "5.6.7.8/32"}},
+			[]interface{}{[]string{"1.2.3.4", "5.6.7.8"}, []string{"2001:db8::1"}, nil},
+		},
+		{
+			"invalid",
+			splitIPs,
+			[]interface{}{[]string{"1.2.3"}},
+			[]interface{}{[]string(nil), []string(nil), fmt.Errorf("Invalid IP: '1.2.3'")},
+		},
+	})
+}
+
+func TestParseAge(t *testing.T) {
+	doTests(t, []test{
+		{
+			"Go duration",
+			parseAge,
+			[]interface{}{"1h30m"},
+			[]interface{}{90 * time.Minute, nil},
+		},
+		{
+			"weeks, days and hours",
+			parseAge,
+			[]interface{}{"1w2d3h"},
+			[]interface{}{(9*24 + 3) * time.Hour, nil},
+		},
+		{
+			"invalid",
+			parseAge,
+			[]interface{}{"xd"},
+			[]interface{}{time.Duration(0), fmt.Errorf("Invalid duration: 'xd'")},
+		},
+	})
+}
+
+func TestCredFilterMatch(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	x := Credential{GetMeApiCredentialId{
+		ApplicationId: 42,
+		Creation:      now.Add(-48 * time.Hour),
+		Status:        "expired",
+	}, "ovh-do"}
+
+	doTests(t, []test{
+		{
+			"expired",
+			credFilter.match,
+			[]interface{}{credFilter{expired: true}, x, now},
+			[]interface{}{true},
+		},
+		{
+			"application name",
+			credFilter.match,
+			[]interface{}{credFilter{app: "ovh-do"}, x, now},
+			[]interface{}{true},
+		},
+		{
+			"application ID",
+			credFilter.match,
+			[]interface{}{credFilter{app: "42"}, x, now},
+			[]interface{}{true},
+		},
+		{
+			"other application",
+			credFilter.match,
+			[]interface{}{credFilter{expired: true, app: "other"}, x, now},
+			[]interface{}{false},
+		},
+		{
+			"old enough",
+			credFilter.match,
+			[]interface{}{credFilter{age: 24 * time.Hour}, x, now},
+			[]interface{}{true},
+		},
+		{
+			"too recent",
+			credFilter.match,
+			[]interface{}{credFilter{age: 72 * time.Hour}, x, now},
+			[]interface{}{false},
+		},
+	})
+}
+
+func TestVPSFilterMatch(t *testing.T) {
+	x := VPS{
+		GetVPSName: GetVPSName{Name: "vps-1234.vps.ovh.net", State: "running"},
+		Ips:        GetVPSNameIps{"51.75.1.2", "2001:41d0:1::1/128"},
+		Datacenter: GetVPSNameDatacenter{Name: "gra3", LongName: "Gravelines"},
+	}
+	cidr := func(s string) *net.IPNet {
+		n, err := parseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	doTests(t, []test{
+		{
+			"no criteria",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{}, x},
+			[]interface{}{true},
+		},
+		{
+			"state and datacenter",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{state: "Running", dc: "gra"}, x},
+			[]interface{}{true},
+		},
+		{
+			"other datacenter",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{dc: "sbg"}, x},
+			[]interface{}{false},
+		},
+		{
+			"name",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{name: regexp.MustCompile(`^vps-12`)}, x},
+			[]interface{}{true},
+		},
+		{
+			"IPv4 CIDR",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{ip: cidr("51.75.0.0/16")}, x},
+			[]interface{}{true},
+		},
+		{
+			"IPv6 address",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{ip: cidr("2001:41d0:1::1")}, x},
+			[]interface{}{true},
+		},
+		{
+			"other network",
+			vpsFilter.match,
+			[]interface{}{vpsFilter{ip: cidr("10.0.0.0/8")}, x},
+			[]interface{}{false},
+		},
+	})
+}
+
+func TestIsSSHUp(t *testing.T) {
+	// listening server, sending b once connected
+	serve := func(b string) string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { l.Close() })
+		go func() {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				c.Write([]byte(b))
+				c.Close()
+			}
+		}()
+		return l.Addr().String()
+	}
+
+	// nothing listening there anymore
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	doTests(t, []test{
+		{
+			"sshd",
+			isSSHUp,
+			[]interface{}{serve("SSH-2.0-OpenSSH_9.2\r\n")},
+			[]interface{}{true},
+		},
+		{
+			"other service",
+			isSSHUp,
+			[]interface{}{serve("220 smtp ready\r\n")},
+			[]interface{}{false},
+		},
+		{
+			"closed port",
+			isSSHUp,
+			[]interface{}{closed},
+			[]interface{}{false},
+		},
+	})
+}
+
+func TestLookupCmd(t *testing.T) {
+	// found command's name, and remaining words
+	f := func(xs ...string) (string, []string) {
+		x, ys := lookupCmd(xs)
+		if x == nil {
+			return "", ys
+		}
+		return x.name, ys
+	}
+
+	doTests(t, []test{
+		{
+			"single word",
+			f,
+			[]interface{}{"ls-ips", "vps-1"},
+			[]interface{}{"ls-ips", []string{"vps-1"}},
+		},
+		{
+			"two words",
+			f,
+			[]interface{}{"snapshot", "create", "vps-1", "before upgrade"},
+			[]interface{}{"snapshot create", []string{"vps-1", "before upgrade"}},
+		},
+		{
+			"two words, no arguments",
+			f,
+			[]interface{}{"cache", "clear"},
+			[]interface{}{"cache clear", []string{}},
+		},
+		{
+			"incomplete",
+			f,
+			[]interface{}{"snapshot"},
+			[]interface{}{"", []string{"snapshot"}},
+		},
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+// Profiles: named sections of confFn, each holding an
+// endpoint and API credentials, e.g.
+//
+//	[customer-a]
+//	endpoint=ovh-ca
+//	application_key=...
+//	application_secret=...
+//	consumer_key=...
+//
+// go-ovh's own endpoint sections ([ovh-eu], [ovh-ca], etc.)
+// are profiles whose endpoint defaults to their name.
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"gopkg.in/ini.v1"
+	"os"
+	"sort"
+)
+
+// selected profile (see -profile); go-ovh's default
+// configuration is used if empty, unless its section
+// relies on secret commands (see usesSecrets()).
+var profile = os.Getenv("OVH_DO_PROFILE")
+
+type Profile struct {
+	Name        string
+	Endpoint    string
+	AppKey      string
+	AppSecret   string
+	ConsumerKey string
+}
+
+// as listed by the profiles command
+type ProfileStatus struct {
+	Name      string `json:"name"`
+	Endpoint  string `json:"endpoint"`
+	Current   bool   `json:"current"`
+	Validated bool   `json:"validated"`
+	Error     string `json:"error,omitempty"`
+}
+
+// sections of f which look like profiles
+func profileNames(f *ini.File) []string {
+	var xs []string
+	for _, s := range f.Sections() {
+		switch s.Name() {
+		case ini.DefaultSection, "default", confSection:
+			continue
+		}
+		if s.HasKey("application_key") {
+			xs = append(xs, s.Name())
+		}
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+func getProfile(f *ini.File, n string) (*Profile, error) {
+	s, err := f.GetSection(n)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown profile '%s' in %s", n, confFn)
+	}
+
+	p := Profile{
+		Name:     n,
+		Endpoint: s.Key("endpoint").MustString(n),
+		AppKey:   s.Key("application_key").String(),
+	}
+	if p.AppSecret, err = getSecret(s, "application_secret"); err != nil {
+		return nil, err
+	}
+	if p.ConsumerKey, err = getSecret(s, "consumer_key"); err != nil {
+		return nil, err
+	}
+	if p.AppKey == "" || p.AppSecret == "" {
+		return nil, fmt.Errorf("Profile '%s': missing application_key or application_secret", n)
+	}
+
+	return &p, nil
+}
+
+// a client for the profile p
+func newProfileClient(p *Profile) (*ovh.Client, error) {
+	c, err := ovh.NewClient(p.Endpoint, p.AppKey, p.AppSecret, p.ConsumerKey)
+	if err != nil {
+		return nil, err
+	}
+	// go-ovh would otherwise fallback to the endpoint's
+	// section, possibly from another account.
+	c.ConsumerKey = p.ConsumerKey
+	return c, nil
+}
+
+// list confFn's profiles, and whether their consumer key
+// is validated; the current profile is starred.
+func lsProfiles() error {
+	f, err := ini.LooseLoad(confFn)
+	if err != nil {
+		return fmt.Errorf("Loading %s: %s", confFn, err)
+	}
+
+	cur := profile
+	if cur == "" {
+		cur = confEndpoint(f)
+	}
+
+	l := newLister(func(y ProfileStatus) {
+		s := "not-validated"
+		if y.Validated {
+			s = "validated"
+		}
+		if y.Error != "" {
+			s = "error: " + y.Error
+		}
+		m := " "
+		if y.Current {
+			m = "*"
+		}
+		fmt.Printf("%s %s %s %s\n", m, y.Name, y.Endpoint, s)
+	})
+
+	for _, n := range profileNames(f) {
+		y := ProfileStatus{Name: n, Current: n == cur}
+		p, err := getProfile(f, n)
+		if err == nil {
+			y.Endpoint = p.Endpoint
+			if p.ConsumerKey != "" {
+				var c *ovh.Client
+				if c, err = newProfileClient(p); err == nil {
+					y.Validated, err = isValidated(c)
+				}
+			}
+		}
+		if err != nil {
+			y.Error = err.Error()
+		}
+		if err := l.add(y); err != nil {
+			return err
+		}
+	}
+
+	return l.done()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/ini.v1"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	f, err := ini.Load([]byte(`
+[default]
+endpoint=ovh-ca
+
+[ovh-do]
+timeout=10m
+
+[ovh-eu]
+application_key=eu-ak
+application_secret=eu-as
+consumer_key=eu-ck
+
+[customer-a]
+endpoint=ovh-ca
+application_key=a-ak
+application_secret=a-as
+
+[customer-b]
+application_key=b-ak
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doTests(t, []test{
+		{
+			"profiles",
+			profileNames,
+			[]interface{}{f},
+			[]interface{}{[]string{"customer-a", "customer-b", "ovh-eu"}},
+		},
+		{
+			"endpoint section",
+			getProfile,
+			[]interface{}{f, "ovh-eu"},
+			[]interface{}{&Profile{"ovh-eu", "ovh-eu", "eu-ak", "eu-as", "eu-ck"}, nil},
+		},
+		{
+			"explicit endpoint, no consumer key",
+			getProfile,
+			[]interface{}{f, "customer-a"},
+			[]interface{}{&Profile{"customer-a", "ovh-ca", "a-ak", "a-as", ""}, nil},
+		},
+		{
+			"missing secret",
+			getProfile,
+			[]interface{}{f, "customer-b"},
+			[]interface{}{
+				(*Profile)(nil),
+				fmt.Errorf("Profile 'customer-b': missing application_key or application_secret"),
+			},
+		},
+		{
+			"unknown profile",
+			getProfile,
+			[]interface{}{f, "customer-c"},
+			[]interface{}{(*Profile)(nil), fmt.Errorf("Unknown profile 'customer-c' in %s", confFn)},
+		},
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+// Progress reports of long-running operations (tasks,
+// credential validation), on stderr.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// tty, log, json, or auto (tty if stderr is a terminal,
+// log otherwise); see -progress
+var progressMode = "auto"
+
+var progressModes = []string{"auto", "tty", "log", "json"}
+
+var progressOut io.Writer = os.Stderr
+
+func setProgressMode(s string) error {
+	for _, x := range progressModes {
+		if x == s {
+			progressMode = s
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid progress mode '%s' (%s)", s, strings.Join(progressModes, ", "))
+}
+
+type progress interface {
+	// called on each pooling
+	update(x taskStatus)
+
+	// the operation is over; failed if err is set
+	end(err error)
+}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// progress reporter for the operation n (e.g. "VPS task 42")
+func newProgress(n string) progress {
+	m := progressMode
+	if m == "auto" {
+		m = "log"
+		if f, ok := progressOut.(*os.File); ok && isTerminal(f) {
+			m = "tty"
+		}
+	}
+
+	switch m {
+	case "tty":
+		return &ttyProgress{name: n, start: time.Now()}
+	case "json":
+		return &jsonProgress{name: n, start: time.Now()}
+	}
+	return &logProgress{name: n}
+}
+
+// remaining time, estimated from the elapsed time d and
+// the progress p (percents); < 0 if unknown.
+func progressETA(d time.Duration, p int) time.Duration {
+	if p <= 0 || p >= 100 {
+		return -1
+	}
+	return d * time.Duration(100-p) / time.Duration(p)
+}
+
+// e.g. "VPS task 42 (reinstallVm): doing"
+func fmtTaskStatus(n string, x taskStatus) string {
+	s := n
+	if x.Type != "" {
+		s += " (" + x.Type + ")"
+	}
+	return s + ": " + x.State
+}
+
+// a single line, updated on each pooling
+type ttyProgress struct {
+	name  string
+	start time.Time
+	drawn bool
+}
+
+func (p *ttyProgress) update(x taskStatus) {
+	d := time.Since(p.start)
+	s := fmtTaskStatus(p.name, x)
+	if x.Progress >= 0 {
+		s += fmt.Sprintf(" %3d%%", x.Progress)
+	}
+	s += " " + d.Round(time.Second).String()
+	if eta := progressETA(d, x.Progress); eta >= 0 {
+		s += ", ETA " + eta.Round(time.Second).String()
+	}
+	fmt.Fprintf(progressOut, "\r\033[K%s", s)
+	p.drawn = true
+}
+
+func (p *ttyProgress) end(err error) {
+	if !p.drawn {
+		return
+	}
+	d := time.Since(p.start).Round(time.Second)
+	if err != nil {
+		fmt.Fprintf(progressOut, "\r\033[K%s: failed after %s\n", p.name, d)
+	} else {
+		fmt.Fprintf(progressOut, "\r\033[K%s: done in %s\n", p.name, d)
+	}
+}
+
+// a log line per status change
+type logProgress struct {
+	name string
+	last taskStatus
+}
+
+func (p *logProgress) update(x taskStatus) {
+	if x == p.last {
+		return
+	}
+	p.last = x
+	if x.Progress >= 0 {
+		log.Printf("%s, %d%%\n", fmtTaskStatus(p.name, x), x.Progress)
+	} else {
+		log.Printf("%s\n", fmtTaskStatus(p.name, x))
+	}
+}
+
+// errors are reported by the caller
+func (p *logProgress) end(err error) {}
+
+// a JSON object per status change, and at the end
+type jsonProgress struct {
+	name  string
+	start time.Time
+	last  taskStatus
+	n     int
+}
+
+type progressEvent struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// "update", "done" or "failed"
+	Event    string  `json:"event"`
+	Type     string  `json:"type,omitempty"`
+	State    string  `json:"state,omitempty"`
+	Progress *int    `json:"progress,omitempty"`
+	Elapsed  float64 `json:"elapsed"`
+	Error    string  `json:"error,omitempty"`
+}
+
+func (p *jsonProgress) emit(e progressEvent) {
+	e.Time = time.Now()
+	e.Operation = p.name
+	e.Elapsed = time.Since(p.start).Seconds()
+	s, err := json.Marshal(e)
+	if err == nil {
+		fmt.Fprintf(progressOut, "%s\n", s)
+	}
+}
+
+func (p *jsonProgress) update(x taskStatus) {
+	if p.n > 0 && x == p.last {
+		return
+	}
+	p.last, p.n = x, p.n+1
+
+	e := progressEvent{Event: "update", Type: x.Type, State: x.State}
+	if x.Progress >= 0 {
+		e.Progress = &x.Progress
+	}
+	p.emit(e)
+}
+
+func (p *jsonProgress) end(err error) {
+	e := progressEvent{Event: "done"}
+	if err != nil {
+		e.Event, e.Error = "failed", err.Error()
+	}
+	p.emit(e)
+}
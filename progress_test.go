@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProgressETA(t *testing.T) {
+	doTests(t, []test{
+		{
+			"halfway",
+			progressETA,
+			[]interface{}{time.Minute, 50},
+			[]interface{}{time.Minute},
+		},
+		{
+			"a quarter",
+			progressETA,
+			[]interface{}{time.Minute, 25},
+			[]interface{}{3 * time.Minute},
+		},
+		{
+			"unknown",
+			progressETA,
+			[]interface{}{time.Minute, -1},
+			[]interface{}{time.Duration(-1)},
+		},
+		{
+			"not started",
+			progressETA,
+			[]interface{}{time.Minute, 0},
+			[]interface{}{time.Duration(-1)},
+		},
+	})
+}
+
+func TestJSONProgress(t *testing.T) {
+	o, m := progressOut, progressMode
+	defer func() { progressOut, progressMode = o, m }()
+
+	// events sent for the updates xs, ending with the
+	// error e, if any
+	f := func(e string, xs ...taskStatus) []string {
+		var b bytes.Buffer
+		progressOut, progressMode = &b, "json"
+
+		p := newProgress("VPS task 42")
+		for _, x := range xs {
+			p.update(x)
+		}
+		if e != "" {
+			p.end(fmt.Errorf("%s", e))
+		} else {
+			p.end(nil)
+		}
+
+		var ys []string
+		d := json.NewDecoder(&b)
+		for d.More() {
+			var e progressEvent
+			if err := d.Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			y := e.Operation + " " + e.Event + " " + e.Type + " " + e.State
+			if e.Progress != nil {
+				y += fmt.Sprintf(" %d", *e.Progress)
+			}
+			ys = append(ys, y+" "+e.Error)
+		}
+		return ys
+	}
+
+	doing := taskStatus{Type: "reinstallVm", State: "doing", Progress: 10}
+
+	doTests(t, []test{
+		{
+			"changes only",
+			f,
+			[]interface{}{"", doing, doing, taskStatus{Type: "reinstallVm", State: "doing", Progress: 60}},
+			[]interface{}{[]string{
+				"VPS task 42 update reinstallVm doing 10 ",
+				"VPS task 42 update reinstallVm doing 60 ",
+				"VPS task 42 done   ",
+			}},
+		},
+		{
+			"failure, unknown progress",
+			f,
+			[]interface{}{"oops", taskStatus{State: "pendingValidation", Progress: -1}},
+			[]interface{}{[]string{
+				"VPS task 42 update  pendingValidation ",
+				"VPS task 42 failed   oops",
+			}},
+		},
+	})
+}
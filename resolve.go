@@ -0,0 +1,140 @@
+package main
+
+// VPSs designated by one of their IPs, or by a DNS name
+// resolving to one of their IPs.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VPSs' IPs, as last scanned; in c's account cache directory.
+// Cached entries are checked by resolveVPS().
+var vpsIPsFn = "vps-ips.json"
+
+func readVPSIPs(c *ovh.Client) map[string][]string {
+	var m map[string][]string
+	if !readCache(filepath.Join(accountCacheDir(c), vpsIPsFn), cacheTTLs["ips"], &m) {
+		return nil
+	}
+	return m
+}
+
+func writeVPSIPs(c *ovh.Client, m map[string][]string) error {
+	return writeCache(filepath.Join(accountCacheDir(c), vpsIPsFn), m)
+}
+
+// fetch the IPs of all VPSs; cached IPs are refreshed.
+// VPSs failing to be scanned are skipped, and their errors
+// returned as an itemErrors.
+func scanVPSIPs(c *ovh.Client) (map[string][]string, error) {
+	var vs []string
+	if err := getRetry(context.Background(), c, "/vps", &vs); err != nil {
+		return nil, err
+	}
+
+	ips := make([]GetVPSNameIps, len(vs))
+	errs := make([]error, len(vs))
+	runJobs(context.Background(), len(vs), 0, func(ctx context.Context, i int) {
+		uncache(c, "/vps/"+vs[i]+"/ips")
+		errs[i] = getRetry(ctx, cloneClient(c), "/vps/"+vs[i]+"/ips", &ips[i])
+	})
+
+	var es itemErrors
+	m := make(map[string][]string, len(vs))
+	for i, v := range vs {
+		if errs[i] != nil {
+			es = append(es, fmt.Errorf("%s: %s", v, errs[i]))
+			continue
+		}
+		m[v] = ips[i]
+	}
+	if len(es) > 0 {
+		return m, es
+	}
+	return m, nil
+}
+
+// names of the VPSs of m having one of the ips, sorted
+func matchVPSIPs(m map[string][]string, ips []net.IP) []string {
+	var xs []string
+	for v, ys := range m {
+		for _, y := range ys {
+			y, _, _ := strings.Cut(y, "/")
+			if z := net.ParseIP(y); z != nil && containsIP(ips, z) {
+				xs = append(xs, v)
+				break
+			}
+		}
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+func containsIP(xs []net.IP, y net.IP) bool {
+	for _, x := range xs {
+		if x.Equal(y) {
+			return true
+		}
+	}
+	return false
+}
+
+// the VPS designated by s: a VPS name, one of its IPs, or a
+// DNS name resolving to its IPs. IPs are looked up in a
+// local cache first; cache hits are checked against the API.
+func resolveVPS(c *ovh.Client, s string) (string, error) {
+	ip := net.ParseIP(s)
+	ips := []net.IP{ip}
+
+	if ip == nil {
+		var vs []string
+		if err := c.Get("/vps", &vs); err != nil {
+			return "", err
+		}
+		for _, v := range vs {
+			if v == s {
+				return s, nil
+			}
+		}
+
+		var err error
+		if ips, err = net.LookupIP(s); err != nil {
+			return "", fmt.Errorf("'%s' is neither a VPS, an IP, nor a resolvable name: %s", s, err)
+		}
+	}
+
+	if m := readVPSIPs(c); m != nil {
+		if xs := matchVPSIPs(m, ips); len(xs) == 1 {
+			ys, err := getIPs(c, xs[0])
+			if err == nil && len(matchVPSIPs(map[string][]string{xs[0]: *ys}, ips)) == 1 {
+				return xs[0], nil
+			}
+		}
+	}
+
+	var es itemErrors
+	m, err := scanVPSIPs(c)
+	if err != nil && !errors.As(err, &es) {
+		return "", err
+	}
+	writeVPSIPs(c, m)
+
+	switch xs := matchVPSIPs(m, ips); len(xs) {
+	case 0:
+		if len(es) > 0 {
+			return "", fmt.Errorf("No VPS found for '%s'; not scanned:\n%s", s, es)
+		}
+		return "", fmt.Errorf("No VPS found for '%s'", s)
+	case 1:
+		return xs[0], nil
+	default:
+		return "", fmt.Errorf("Ambiguous '%s': matches %s", s, strings.Join(xs, ", "))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchVPSIPs(t *testing.T) {
+	m := map[string][]string{
+		"vps-1.vps.ovh.net": {"51.75.1.1", "2001:41d0:1::1/128"},
+		"vps-2.vps.ovh.net": {"51.75.1.2", "2001:41d0:1::2/128"},
+		"vps-3.vps.ovh.net": {"51.75.1.3", "51.75.1.2"},
+	}
+	ips := func(xs ...string) []net.IP {
+		var ys []net.IP
+		for _, x := range xs {
+			ys = append(ys, net.ParseIP(x))
+		}
+		return ys
+	}
+
+	doTests(t, []test{
+		{
+			"IPv4",
+			matchVPSIPs,
+			[]interface{}{m, ips("51.75.1.1")},
+			[]interface{}{[]string{"vps-1.vps.ovh.net"}},
+		},
+		{
+			"IPv6, non-canonical",
+			matchVPSIPs,
+			[]interface{}{m, ips("2001:41d0:1:0::2")},
+			[]interface{}{[]string{"vps-2.vps.ovh.net"}},
+		},
+		{
+			"name resolving to several IPs of a VPS",
+			matchVPSIPs,
+			[]interface{}{m, ips("51.75.1.1", "2001:41d0:1::1")},
+			[]interface{}{[]string{"vps-1.vps.ovh.net"}},
+		},
+		{
+			"ambiguous",
+			matchVPSIPs,
+			[]interface{}{m, ips("51.75.1.2")},
+			[]interface{}{[]string{"vps-2.vps.ovh.net", "vps-3.vps.ovh.net"}},
+		},
+		{
+			"unknown",
+			matchVPSIPs,
+			[]interface{}{m, ips("10.0.0.1")},
+			[]interface{}{[]string(nil)},
+		},
+	})
+}
+
+func TestResolveVPS(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// vps-2's IPs can't be fetched
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/time":
+			fmt.Fprint(w, time.Now().Unix())
+		case "/vps":
+			fmt.Fprint(w, `["vps-1", "vps-2"]`)
+		case "/vps/vps-1/ips":
+			fmt.Fprint(w, `["51.75.1.1"]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	}))
+	defer s.Close()
+
+	c, err := ovh.NewClient(s.URL, "ak", "as", "ck")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doTests(t, []test{
+		{
+			"match despite a failing VPS",
+			resolveVPS,
+			[]interface{}{c, "51.75.1.1"},
+			[]interface{}{"vps-1", nil},
+		},
+		{
+			"no match",
+			resolveVPS,
+			[]interface{}{c, "51.75.1.2"},
+			[]interface{}{"", fmt.Errorf("No VPS found for '51.75.1.2'; not scanned:\n" +
+				`vps-2: HTTP Error 404: "not found"`)},
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+// Access rules requested with new consumer keys.
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"sort"
+	"strings"
+)
+
+// Named sets of rules, as comma-separated "METHOD path"; RO
+// stands for GET, RW for GET, POST, PUT and DELETE. GET /me is
+// always needed to check a key's validation.
+//
+// More can be defined in the [ovh-do] section of confFn, e.g.
+//
+//	scope.ci=RO /vps, RO /vps/*, POST /vps/*/reboot
+var scopes = map[string]string{
+	"all":          "RW /*",
+	"dns-only":     "RW /domain/zone, RW /domain/zone/*",
+	"vps-readonly": "RO /vps, RO /vps/*",
+}
+
+// scope used for new consumer keys (see -scope)
+var scope = "all"
+
+func scopeNames() []string {
+	xs := make([]string, 0, len(scopes))
+	for x := range scopes {
+		xs = append(xs, x)
+	}
+	sort.Strings(xs)
+	return xs
+}
+
+func setScope(s string) error {
+	if _, ok := scopes[s]; !ok {
+		return fmt.Errorf("Unknown scope '%s' (%s)", s, strings.Join(scopeNames(), ", "))
+	}
+	scope = s
+	return nil
+}
+
+// parse a scope's rules; GET /me is added if missing
+func parseScope(s string) ([]ovh.AccessRule, error) {
+	var xs []ovh.AccessRule
+	me := false
+
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		ys := strings.Fields(r)
+		if len(ys) != 2 || !strings.HasPrefix(ys[1], "/") {
+			return nil, fmt.Errorf("Invalid rule '%s'", r)
+		}
+
+		var ms []string
+		switch m := strings.ToUpper(ys[0]); m {
+		case "RO":
+			ms = ovh.ReadOnly
+		case "RW":
+			ms = ovh.ReadWrite
+		case "GET", "POST", "PUT", "DELETE":
+			ms = []string{m}
+		default:
+			return nil, fmt.Errorf("Invalid method in rule '%s'", r)
+		}
+
+		for _, m := range ms {
+			xs = append(xs, ovh.AccessRule{Method: m, Path: ys[1]})
+			if m == "GET" && (ys[1] == "/me" || ys[1] == "/*") {
+				me = true
+			}
+		}
+	}
+
+	if len(xs) == 0 {
+		return nil, fmt.Errorf("Empty scope")
+	}
+	if !me {
+		xs = append([]ovh.AccessRule{{Method: "GET", Path: "/me"}}, xs...)
+	}
+
+	return xs, nil
+}
+
+// rules of the current scope
+func getScopeRules() ([]ovh.AccessRule, error) {
+	xs, err := parseScope(scopes[scope])
+	if err != nil {
+		return nil, fmt.Errorf("Scope %s: %s", scope, err)
+	}
+	return xs, nil
+}
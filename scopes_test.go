@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"testing"
+)
+
+func TestParseScope(t *testing.T) {
+	me := ovh.AccessRule{Method: "GET", Path: "/me"}
+	doTests(t, []test{
+		{
+			"all",
+			parseScope,
+			[]interface{}{scopes["all"]},
+			[]interface{}{[]ovh.AccessRule{
+				{Method: "GET", Path: "/*"},
+				{Method: "POST", Path: "/*"},
+				{Method: "PUT", Path: "/*"},
+				{Method: "DELETE", Path: "/*"},
+			}, nil},
+		},
+		{
+			"GET /me added",
+			parseScope,
+			[]interface{}{"ro /vps/*, POST /vps/*/reboot"},
+			[]interface{}{[]ovh.AccessRule{
+				me,
+				{Method: "GET", Path: "/vps/*"},
+				{Method: "POST", Path: "/vps/*/reboot"},
+			}, nil},
+		},
+		{
+			"invalid method",
+			parseScope,
+			[]interface{}{"PATCH /vps"},
+			[]interface{}{[]ovh.AccessRule(nil), fmt.Errorf("Invalid method in rule 'PATCH /vps'")},
+		},
+		{
+			"invalid rule",
+			parseScope,
+			[]interface{}{"GET"},
+			[]interface{}{[]ovh.AccessRule(nil), fmt.Errorf("Invalid rule 'GET'")},
+		},
+		{
+			"empty",
+			parseScope,
+			[]interface{}{" , "},
+			[]interface{}{[]ovh.AccessRule(nil), fmt.Errorf("Empty scope")},
+		},
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+// Secrets (application_secret, consumer_key) kept out of
+// confFn: fetched from, and stored with, external commands.
+//
+//	[ovh-eu]
+//	application_key=...
+//	application_secret_cmd=pass show ovh/application_secret
+//	consumer_key_cmd=pass show ovh/consumer_key
+//	consumer_key_store_cmd=pass insert -m -f ovh/consumer_key
+//
+// or, for a Secret Service store (GNOME Keyring, KeePassXC,
+// etc.), through secret-tool(1):
+//
+//	[ovh-eu]
+//	application_key=...
+//	secrets=secret-tool
+//
+// Commands are run by sh(1), with $OVH_DO_SECTION and
+// $OVH_DO_KEY set; values are read from/written to their
+// standard output/input. Commands containing ';' or '#'
+// must be enclosed in """ (inline comments otherwise).
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/ini.v1"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// keys which can be handled by secret commands
+var secretKeys = []string{"application_secret", "consumer_key"}
+
+// built-in backends: lookup and store commands
+var secretBackends = map[string][2]string{
+	"secret-tool": {
+		`secret-tool lookup service ovh-do section "$OVH_DO_SECTION" key "$OVH_DO_KEY"`,
+		`secret-tool store --label="ovh-do $OVH_DO_SECTION $OVH_DO_KEY" ` +
+			`service ovh-do section "$OVH_DO_SECTION" key "$OVH_DO_KEY"`,
+	},
+}
+
+// whether s relies on secret commands
+func usesSecrets(s *ini.Section) bool {
+	if s.HasKey("secrets") {
+		return true
+	}
+	for _, k := range secretKeys {
+		if s.HasKey(k+"_cmd") || s.HasKey(k+"_store_cmd") {
+			return true
+		}
+	}
+	return false
+}
+
+// command to lookup (i = 0) or store (i = 1) the key k of s;
+// empty if none.
+func secretCmd(s *ini.Section, k string, i int) (string, error) {
+	n := k + "_cmd"
+	if i == 1 {
+		n = k + "_store_cmd"
+	}
+	if s.HasKey(n) {
+		return s.Key(n).String(), nil
+	}
+	if !s.HasKey("secrets") {
+		return "", nil
+	}
+
+	b, ok := secretBackends[s.Key("secrets").String()]
+	if !ok {
+		return "", fmt.Errorf("[%s] secrets: unknown backend '%s'", s.Name(), s.Key("secrets").String())
+	}
+	return b[i], nil
+}
+
+func runSecretCmd(s *ini.Section, k, cmd, in string) (string, error) {
+	var o, e bytes.Buffer
+
+	c := exec.Command("sh", "-c", cmd)
+	c.Env = append(os.Environ(), "OVH_DO_SECTION="+s.Name(), "OVH_DO_KEY="+k)
+	c.Stdin = strings.NewReader(in)
+	c.Stdout = &o
+	c.Stderr = &e
+
+	if err := c.Run(); err != nil {
+		// e.g. secret-tool lookup of a missing key
+		var xerr *exec.ExitError
+		if errors.As(err, &xerr) && o.Len() == 0 && e.Len() == 0 && in == "" {
+			return "", nil
+		}
+		m := err.Error()
+		if x := strings.TrimSpace(e.String()); x != "" {
+			m += ": " + x
+		}
+		return "", fmt.Errorf("[%s] %s: running '%s': %s", s.Name(), k, cmd, m)
+	}
+
+	return strings.TrimRight(o.String(), "\r\n"), nil
+}
+
+// value of the key k of s: from its command if any, from s
+// otherwise.
+func getSecret(s *ini.Section, k string) (string, error) {
+	cmd, err := secretCmd(s, k, 0)
+	if err != nil || cmd == "" {
+		return s.Key(k).String(), err
+	}
+	return runSecretCmd(s, k, cmd, "")
+}
+
+// store v as the key k of s, if s has a store command for it;
+// returns false otherwise. Keys looked up by a command but
+// lacking a store command can't be stored (their value in s
+// would be ignored).
+func storeSecret(s *ini.Section, k, v string) (bool, error) {
+	cmd, err := secretCmd(s, k, 1)
+	if err != nil {
+		return false, err
+	}
+	if cmd == "" {
+		if x, _ := secretCmd(s, k, 0); x != "" {
+			return false, fmt.Errorf("[%s] %s: %s_cmd set, but no %s_store_cmd", s.Name(), k, k, k)
+		}
+		return false, nil
+	}
+	_, err = runSecretCmd(s, k, cmd, v)
+	return true, err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/ini.v1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "ck")
+
+	f, err := ini.Load([]byte(fmt.Sprintf(`
+[plain]
+application_secret=as
+
+[cmd]
+application_secret=ignored
+application_secret_cmd=echo "$OVH_DO_SECTION-$OVH_DO_KEY"
+consumer_key_cmd=cat %[1]s
+consumer_key_store_cmd=cat > %[1]s
+
+[lookup]
+consumer_key_cmd=echo ck
+
+[failing]
+application_secret_cmd="""echo oops >&2; exit 1"""
+
+[backend]
+secrets=vault
+`, fn)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// store, then read back
+	g := func(s, k, v string) (bool, error, string, error) {
+		ok, err := storeSecret(f.Section(s), k, v)
+		x, err2 := getSecret(f.Section(s), k)
+		return ok, err, x, err2
+	}
+
+	doTests(t, []test{
+		{
+			"plain value",
+			getSecret,
+			[]interface{}{f.Section("plain"), "application_secret"},
+			[]interface{}{"as", nil},
+		},
+		{
+			"command",
+			getSecret,
+			[]interface{}{f.Section("cmd"), "application_secret"},
+			[]interface{}{"cmd-application_secret", nil},
+		},
+		{
+			"failing command",
+			getSecret,
+			[]interface{}{f.Section("failing"), "application_secret"},
+			[]interface{}{"", fmt.Errorf("[failing] application_secret: running " +
+				"'echo oops >&2; exit 1': exit status 1: oops")},
+		},
+		{
+			"unknown backend",
+			getSecret,
+			[]interface{}{f.Section("backend"), "consumer_key"},
+			[]interface{}{"", fmt.Errorf("[backend] secrets: unknown backend 'vault'")},
+		},
+		{
+			"no store command",
+			g,
+			[]interface{}{"plain", "consumer_key", "ck"},
+			[]interface{}{false, nil, "", nil},
+		},
+		{
+			"lookup command only",
+			g,
+			[]interface{}{"lookup", "consumer_key", "new"},
+			[]interface{}{false, fmt.Errorf("[lookup] consumer_key: consumer_key_cmd set, " +
+				"but no consumer_key_store_cmd"), "ck", nil},
+		},
+		{
+			"store command",
+			g,
+			[]interface{}{"cmd", "consumer_key", "ck"},
+			[]interface{}{true, nil, "ck", nil},
+		},
+	})
+
+	if _, err := os.Stat(fn); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+// VPS snapshots (a VPS has at most one snapshot).
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"time"
+)
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/snapshot~GET
+type GetVPSNameSnapshot struct {
+	CreationDate time.Time `json:"creationDate"`
+	Description  string    `json:"description"`
+	Id           int       `json:"id"`
+	Region       string    `json:"region"`
+}
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/createSnapshot~POST
+type PostInVPSNameCreateSnapshot struct {
+	Description string `json:"description,omitempty"`
+}
+type PostOutVPSNameCreateSnapshot VPSTask
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/snapshot~DELETE
+type DeleteVPSNameSnapshot VPSTask
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/snapshot/revert~POST
+type PostInVPSNameSnapshotRevert struct{}
+type PostOutVPSNameSnapshotRevert VPSTask
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/snapshot/download~GET
+type GetVPSNameSnapshotDownload struct {
+	Size int64  `json:"size"`
+	Url  string `json:"url"`
+}
+
+func createSnapshot(c *ovh.Client, v, d string) error {
+	x := PostInVPSNameCreateSnapshot{d}
+	var y PostOutVPSNameCreateSnapshot
+	if err := c.Post("/vps/"+v+"/createSnapshot", &x, &y); err != nil {
+		return err
+	}
+	return poolTask(c, v, y.Id)
+}
+
+func showSnapshot(c *ovh.Client, v string) error {
+	var x GetVPSNameSnapshot
+	if err := c.Get("/vps/"+v+"/snapshot", &x); err != nil {
+		return err
+	}
+
+	l := newLister(func(x GetVPSNameSnapshot) {
+		fmt.Printf("id:          %d\n", x.Id)
+		fmt.Printf("creation:    %s\n", x.CreationDate.Format(time.RFC3339))
+		fmt.Printf("description: %s\n", x.Description)
+		fmt.Printf("region:      %s\n", x.Region)
+	})
+	if err := l.add(x); err != nil {
+		return err
+	}
+	return l.done()
+}
+
+func rmSnapshot(c *ovh.Client, v string) error {
+	var y DeleteVPSNameSnapshot
+	if err := c.Delete("/vps/"+v+"/snapshot", &y); err != nil {
+		return err
+	}
+	return poolTask(c, v, y.Id)
+}
+
+// revert v to its snapshot
+func restoreSnapshot(c *ovh.Client, v string) error {
+	var x PostInVPSNameSnapshotRevert
+	var y PostOutVPSNameSnapshotRevert
+	if err := c.Post("/vps/"+v+"/snapshot/revert", &x, &y); err != nil {
+		return err
+	}
+	return poolTask(c, v, y.Id)
+}
+
+func getSnapshotURL(c *ovh.Client, v string) error {
+	var x GetVPSNameSnapshotDownload
+	if err := c.Get("/vps/"+v+"/snapshot/download", &x); err != nil {
+		return err
+	}
+	fmt.Println(x.Url)
+	return nil
+}
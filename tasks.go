@@ -0,0 +1,184 @@
+package main
+
+// Asynchronous API tasks (VPS, DNS zones): waiting for their
+// completion. IP tasks (/ip/{ip}/task) aren't handled, as no
+// command operates on IPs.
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// https://api.ovh.com/console/#/vps/%7BserviceName%7D/tasks~GET
+type GetVPSNameTasks []int
+
+// a task's status, as seen by watchTask()
+type taskStatus struct {
+	Type     string
+	State    string
+	Comment  string
+	Progress int // < 0 if unknown
+
+	done, failed bool
+}
+
+// a task to be watched
+type taskWatcher struct {
+	// e.g. "VPS task 42", for messages
+	name    string
+	timeout time.Duration
+	get     func(c *ovh.Client) (taskStatus, error)
+}
+
+func vpsTask(v string, i int) taskWatcher {
+	return taskWatcher{
+		name:    "VPS task " + strconv.Itoa(i),
+		timeout: poolRebuildTimeout,
+		get: func(c *ovh.Client) (taskStatus, error) {
+			var x GetVPSNameTasksId
+			err := c.Get("/vps/"+v+"/tasks/"+strconv.Itoa(i), &x)
+			return taskStatus{
+				Type:     x.Type,
+				State:    x.State,
+				Progress: x.Progress,
+				done:     x.State == "done",
+				failed:   x.State == "error" || x.State == "cancelled",
+			}, err
+		},
+	}
+}
+
+func zoneTask(z string, i int) taskWatcher {
+	return taskWatcher{
+		name:    "Zone task " + strconv.Itoa(i),
+		timeout: poolZoneTaskTimeout,
+		get: func(c *ovh.Client) (taskStatus, error) {
+			var x GetDomainZoneZoneNameTaskId
+			err := c.Get("/domain/zone/"+z+"/task/"+strconv.Itoa(i), &x)
+			return taskStatus{
+				Type:     x.Function,
+				State:    x.Status,
+				Comment:  x.Comment,
+				Progress: -1,
+				done:     x.Status == "done",
+				failed:   x.Status == "error" || x.Status == "cancelled",
+			}, err
+		},
+	}
+}
+
+// wait for w's task to be done; failed tasks are errors.
+// Progress is reported (see newProgress()).
+func watchTask(c *ovh.Client, w taskWatcher) (err error) {
+	p := newProgress(w.name)
+	defer func() { p.end(err) }()
+
+	a := time.Now().Add(w.timeout)
+	for {
+		time.Sleep(poolInterval)
+		if time.Now().After(a) {
+			break
+		}
+
+		x, err := w.get(c)
+		if err != nil {
+			return err
+		}
+		if x.failed {
+			if x.Comment != "" {
+				return fmt.Errorf("%s (%s) %s: %s", w.name, x.Type, x.State, x.Comment)
+			}
+			return fmt.Errorf("%s (%s) %s", w.name, x.Type, x.State)
+		}
+		if x.done {
+			return nil
+		}
+		p.update(x)
+	}
+
+	return fmt.Errorf("%s: pooling timeout", w.name)
+}
+
+// VPS task type of rebuilds
+const rebuildTaskType = "reinstallVm"
+
+// command resuming the wait of v's task i; n as for waitTask()
+func waitTaskCmd(v string, i int, n string) string {
+	if n != "" {
+		return fmt.Sprintf("ovh-do wait-task -dns %s %s %d", n, v, i)
+	}
+	return fmt.Sprintf("ovh-do wait-task %s %d", v, i)
+}
+
+// wait for v's task i; the wait can be resumed with wait-task
+func poolTask(c *ovh.Client, v string, i int) error {
+	log.Printf("Waiting for VPS task %d (resume with: %s)\n", i, waitTaskCmd(v, i, ""))
+	return watchTask(c, vpsTask(v, i))
+}
+
+// wait for v's task i, as wait-task: rebuilds are finished as
+// by rebuild (known hosts reset, and if n is set, v's IPs
+// registered under this FQDN).
+func waitTask(c *ovh.Client, v string, i int, n string) error {
+	var z, sd string
+	if n != "" {
+		var err error
+		if z, sd, err = findZone(c, n); err != nil {
+			return err
+		}
+	}
+
+	var t string
+	w := vpsTask(v, i)
+	get := w.get
+	w.get = func(c *ovh.Client) (taskStatus, error) {
+		x, err := get(c)
+		t = x.Type
+		return x, err
+	}
+	if err := watchTask(c, w); err != nil {
+		return err
+	}
+
+	if t != rebuildTaskType {
+		if n != "" {
+			return fmt.Errorf("VPS task %d (%s) isn't a rebuild: -dns ignored", i, t)
+		}
+		return nil
+	}
+	time.Sleep(waitVPSUp)
+	if err := resetKnownHosts(c, v); err != nil {
+		return err
+	}
+	if n != "" {
+		return registerVPS(c, v, z, sd)
+	}
+	return nil
+}
+
+// wait for z's task i
+func poolZoneTask(c *ovh.Client, z string, i int) error {
+	if err := watchTask(c, zoneTask(z, i)); err != nil {
+		return err
+	}
+	uncache(c, "/domain/zone/"+z)
+	return nil
+}
+
+// list v's tasks, optionally in state s only
+func lsTasks(c *ovh.Client, v, s string) error {
+	r := "/vps/" + v + "/tasks"
+	if s != "" {
+		r += "?" + url.Values{"state": {s}}.Encode()
+	}
+
+	l := newLister(func(y GetVPSNameTasksId) {
+		fmt.Printf("%d\t%s\t%s\t%d%%\t%s\n", y.Id, y.Type, y.State, y.Progress,
+			y.DateTime.Format(time.RFC3339))
+	})
+	return listItems(c, r, l, strconv.Itoa)
+}
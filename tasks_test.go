@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ovh/go-ovh/ovh"
+	"testing"
+	"time"
+)
+
+func TestWatchTask(t *testing.T) {
+	p := poolInterval
+	defer func() { poolInterval = p }()
+	poolInterval = time.Millisecond
+
+	// a task going through the states xs, the last one
+	// being repeated
+	w := func(xs ...taskStatus) taskWatcher {
+		return taskWatcher{
+			name:    "VPS task 42",
+			timeout: time.Second,
+			get: func(*ovh.Client) (taskStatus, error) {
+				x := xs[0]
+				if len(xs) > 1 {
+					xs = xs[1:]
+				}
+				return x, nil
+			},
+		}
+	}
+	doing := taskStatus{Type: "reinstallVm", State: "doing", Progress: 50}
+
+	doTests(t, []test{
+		{
+			"done",
+			watchTask,
+			[]interface{}{(*ovh.Client)(nil), w(doing, taskStatus{State: "done", done: true})},
+			[]interface{}{nil},
+		},
+		{
+			"error",
+			watchTask,
+			[]interface{}{(*ovh.Client)(nil), w(doing,
+				taskStatus{Type: "reinstallVm", State: "error", failed: true})},
+			[]interface{}{fmt.Errorf("VPS task 42 (reinstallVm) error")},
+		},
+		{
+			"cancelled, with a comment",
+			watchTask,
+			[]interface{}{(*ovh.Client)(nil), w(taskStatus{Type: "import", State: "cancelled",
+				Comment: "invalid zone", failed: true})},
+			[]interface{}{fmt.Errorf("VPS task 42 (import) cancelled: invalid zone")},
+		},
+	})
+
+	x := w(doing)
+	x.timeout = 10 * time.Millisecond
+	doTests(t, []test{
+		{
+			"timeout",
+			watchTask,
+			[]interface{}{(*ovh.Client)(nil), x},
+			[]interface{}{fmt.Errorf("VPS task 42: pooling timeout")},
+		},
+	})
+}
@@ -0,0 +1,437 @@
+package main
+
+// Minimal BIND zone file parsing, enough to compare two
+// versions of a zone at the record level.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ZoneRecord struct {
+	Name  string
+	TTL   int
+	Class string
+	Type  string
+	Data  string
+}
+
+func (r ZoneRecord) String() string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", r.Name, r.TTL, r.Class, r.Type, r.Data)
+}
+
+// record types whose data contains domain names, and the
+// (0-based) position of those names in the data fields.
+var zoneNameFields = map[string][]int{
+	"CNAME": {0},
+	"DNAME": {0},
+	"NS":    {0},
+	"PTR":   {0},
+	"MX":    {1},
+	"SRV":   {3},
+	"SOA":   {0, 1},
+}
+
+// remove a ';' comment from s, ignoring ';' within quotes
+func stripZoneComment(s string) string {
+	q := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			q = !q
+		case ';':
+			if !q {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// split s on blanks; quoted strings are kept as a single
+// token, quotes included. Parentheses are dropped.
+func splitZoneLine(s string) []string {
+	var xs []string
+	var b strings.Builder
+	q := false
+
+	flush := func() {
+		if b.Len() > 0 {
+			xs = append(xs, b.String())
+			b.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			b.WriteByte(c)
+			b.WriteByte(s[i+1])
+			i++
+		case c == '"':
+			b.WriteByte(c)
+			q = !q
+		case q:
+			b.WriteByte(c)
+		case c == '(' || c == ')':
+			flush()
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+
+	return xs
+}
+
+// parse a BIND TTL, e.g. 3600 or 1h30m
+func parseZoneTTL(s string) (int, error) {
+	units := map[byte]int{
+		's': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800,
+	}
+
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n, nil
+	}
+
+	t, n := 0, -1
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20
+		if s[i] >= '0' && s[i] <= '9' {
+			if n < 0 {
+				n = 0
+			}
+			n = n*10 + int(s[i]-'0')
+		} else if u, ok := units[c]; ok && n >= 0 {
+			t += n * u
+			n = -1
+		} else {
+			return -1, fmt.Errorf("Invalid TTL: '%s'", s)
+		}
+	}
+	if n >= 0 || s == "" {
+		return -1, fmt.Errorf("Invalid TTL: '%s'", s)
+	}
+	return t, nil
+}
+
+func isZoneClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+// qualify n relatively to origin o; the result is
+// lowercased and has no trailing dot.
+func qualifyZoneName(n, o string) string {
+	n = strings.ToLower(n)
+	if n == "@" {
+		return o
+	}
+	if strings.HasSuffix(n, ".") {
+		return strings.TrimSuffix(n, ".")
+	}
+	if o == "" {
+		return n
+	}
+	return n + "." + o
+}
+
+// reverse of qualifyZoneName(): n relatively to origin o,
+// "@" for the origin itself.
+func relZoneName(n, o string) string {
+	if n == o {
+		return "@"
+	}
+	if o != "" && strings.HasSuffix(n, "."+o) {
+		return strings.TrimSuffix(n, "."+o)
+	}
+	return n + "."
+}
+
+// join lines spanning over parentheses into single
+// logical lines; comments are removed.
+func joinZoneLines(s string) []string {
+	var xs []string
+	var b strings.Builder
+	depth := 0
+
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimRight(stripZoneComment(l), " \t\r")
+		q := false
+		for i := 0; i < len(l); i++ {
+			switch {
+			case l[i] == '\\':
+				i++
+			case l[i] == '"':
+				q = !q
+			case q:
+			case l[i] == '(':
+				depth++
+			case l[i] == ')':
+				depth--
+			}
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(l)
+		if depth <= 0 {
+			xs = append(xs, b.String())
+			b.Reset()
+			depth = 0
+		}
+	}
+	if b.Len() > 0 {
+		xs = append(xs, b.String())
+	}
+
+	return xs
+}
+
+// parse a zone file s for the zone o (e.g. example.com).
+// Names are qualified, lowercased, and then expressed relatively
+// to o; TTLs are made explicit.
+func parseZone(s, o string) ([]ZoneRecord, error) {
+	var xs []ZoneRecord
+
+	o = strings.TrimSuffix(strings.ToLower(o), ".")
+	origin := o
+	defTTL, lastTTL := -1, 0
+	owner := ""
+
+	for i, l := range joinZoneLines(s) {
+		ts := splitZoneLine(l)
+		if len(ts) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(ts[0]) {
+		case "$TTL":
+			if len(ts) < 2 {
+				return nil, fmt.Errorf("Line %d: missing $TTL value", i+1)
+			}
+			n, err := parseZoneTTL(ts[1])
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: %s", i+1, err)
+			}
+			defTTL = n
+			continue
+		case "$ORIGIN":
+			if len(ts) < 2 {
+				return nil, fmt.Errorf("Line %d: missing $ORIGIN value", i+1)
+			}
+			origin = qualifyZoneName(ts[1], origin)
+			continue
+		case "$INCLUDE", "$GENERATE":
+			return nil, fmt.Errorf("Line %d: %s not supported", i+1, ts[0])
+		}
+
+		// blank-prefixed lines re-use the previous owner
+		if l[0] != ' ' && l[0] != '\t' {
+			owner = qualifyZoneName(ts[0], origin)
+			ts = ts[1:]
+		} else if owner == "" {
+			return nil, fmt.Errorf("Line %d: no owner", i+1)
+		}
+
+		r := ZoneRecord{Name: relZoneName(owner, o), TTL: -1, Class: "IN"}
+
+		// [ttl] [class] or [class] [ttl]
+		for j := 0; j < 2 && len(ts) > 0; j++ {
+			if isZoneClass(ts[0]) {
+				r.Class = strings.ToUpper(ts[0])
+				ts = ts[1:]
+			} else if n, err := parseZoneTTL(ts[0]); err == nil {
+				r.TTL = n
+				ts = ts[1:]
+			}
+		}
+
+		if len(ts) == 0 {
+			return nil, fmt.Errorf("Line %d: missing record type", i+1)
+		}
+		r.Type = strings.ToUpper(ts[0])
+		ts = ts[1:]
+
+		for _, j := range zoneNameFields[r.Type] {
+			if j < len(ts) {
+				ts[j] = qualifyZoneName(ts[j], origin) + "."
+			}
+		}
+
+		// serial changes with every edit; it's noise.
+		if r.Type == "SOA" && len(ts) > 2 {
+			ts[2] = "-"
+		}
+
+		r.Data = strings.Join(ts, " ")
+
+		if r.TTL == -1 {
+			if defTTL != -1 {
+				r.TTL = defTTL
+			} else {
+				r.TTL = lastTTL
+			}
+		}
+		lastTTL = r.TTL
+
+		xs = append(xs, r)
+	}
+
+	return xs, nil
+}
+
+// parse a zone file and return its records as sorted
+// strings, ready to be compared.
+func normalizeZone(s, o string) ([]string, error) {
+	rs, err := parseZone(s, o)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool {
+		a, b := rs[i], rs[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Data != b.Data {
+			return a.Data < b.Data
+		}
+		return a.TTL < b.TTL
+	})
+
+	xs := make([]string, 0, len(rs))
+	for i, r := range rs {
+		// duplicated records are meaningless
+		if i > 0 && r == rs[i-1] {
+			continue
+		}
+		xs = append(xs, r.String())
+	}
+
+	return xs, nil
+}
+
+// unified diff between xs and ys (labeled a and b), with n
+// lines of context. Empty if xs and ys are equal.
+func unifiedDiff(xs, ys []string, a, b string, n int) string {
+	// classic LCS table; zones are small.
+	l := make([][]int, len(xs)+1)
+	for i := range l {
+		l[i] = make([]int, len(ys)+1)
+	}
+	for i := len(xs) - 1; i >= 0; i-- {
+		for j := len(ys) - 1; j >= 0; j-- {
+			if xs[i] == ys[j] {
+				l[i][j] = l[i+1][j+1] + 1
+			} else if l[i+1][j] >= l[i][j+1] {
+				l[i][j] = l[i+1][j]
+			} else {
+				l[i][j] = l[i][j+1]
+			}
+		}
+	}
+
+	// edit script: ' ', '-' or '+', with the positions
+	// in xs and ys before the edit
+	type edit struct {
+		op   byte
+		i, j int
+	}
+	var es []edit
+	i, j := 0, 0
+	for i < len(xs) || j < len(ys) {
+		switch {
+		case i < len(xs) && j < len(ys) && xs[i] == ys[j]:
+			es = append(es, edit{' ', i, j})
+			i++
+			j++
+		case i < len(xs) && (j == len(ys) || l[i+1][j] >= l[i][j+1]):
+			es = append(es, edit{'-', i, j})
+			i++
+		default:
+			es = append(es, edit{'+', i, j})
+			j++
+		}
+	}
+
+	var s strings.Builder
+	prev := 0
+	for k := 0; k < len(es); {
+		if es[k].op == ' ' {
+			k++
+			continue
+		}
+
+		// hunk boundaries: extend while changes are
+		// separated by at most 2*n unchanged lines.
+		start := k - n
+		if start < prev {
+			start = prev
+		}
+		end := k
+		for m := k; m < len(es); m++ {
+			if es[m].op != ' ' {
+				end = m
+			} else if m-end > 2*n {
+				break
+			}
+		}
+		end += n + 1
+		if end > len(es) {
+			end = len(es)
+		}
+
+		if s.Len() == 0 {
+			fmt.Fprintf(&s, "--- %s\n+++ %s\n", a, b)
+		}
+
+		na, nb := 0, 0
+		for _, e := range es[start:end] {
+			if e.op != '+' {
+				na++
+			}
+			if e.op != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&s, "@@ -%s +%s @@\n",
+			hunkRange(es[start].i, na), hunkRange(es[start].j, nb))
+		for _, e := range es[start:end] {
+			switch e.op {
+			case '+':
+				fmt.Fprintf(&s, "+%s\n", ys[e.j])
+			default:
+				fmt.Fprintf(&s, "%c%s\n", e.op, xs[e.i])
+			}
+		}
+
+		k, prev = end, end
+	}
+
+	return s.String()
+}
+
+// diff(1)'s hunk range for n lines starting at (0-based) i
+func hunkRange(i, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", i)
+	}
+	if n == 1 {
+		return strconv.Itoa(i + 1)
+	}
+	return fmt.Sprintf("%d,%d", i+1, n)
+}
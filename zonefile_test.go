@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseZoneTTL(t *testing.T) {
+	doTests(t, []test{
+		{
+			"seconds",
+			parseZoneTTL,
+			[]interface{}{"3600"},
+			[]interface{}{3600, nil},
+		},
+		{
+			"units",
+			parseZoneTTL,
+			[]interface{}{"1h30M"},
+			[]interface{}{5400, nil},
+		},
+		{
+			"trailing number",
+			parseZoneTTL,
+			[]interface{}{"1h30"},
+			[]interface{}{-1, fmt.Errorf("Invalid TTL: '1h30'")},
+		},
+		{
+			"record type",
+			parseZoneTTL,
+			[]interface{}{"MX"},
+			[]interface{}{-1, fmt.Errorf("Invalid TTL: 'MX'")},
+		},
+	})
+}
+
+func TestNormalizeZone(t *testing.T) {
+	doTests(t, []test{
+		{
+			"OVH export",
+			normalizeZone,
+			[]interface{}{`$TTL 3600
+@	IN SOA dns100.ovh.net. tech.ovh.net. (2023010101 86400 3600 3600000 300)
+                          IN NS     ns100.ovh.net.
+                          IN NS     dns100.ovh.net.
+www                       IN A      1.2.3.4
+_dmarc           60       IN TXT    "v=DMARC1; p=none" ; comment
+`, "example.com"},
+			[]interface{}{[]string{
+				"@\t3600\tIN\tNS\tdns100.ovh.net.",
+				"@\t3600\tIN\tNS\tns100.ovh.net.",
+				"@\t3600\tIN\tSOA\tdns100.ovh.net. tech.ovh.net. - 86400 3600 3600000 300",
+				"_dmarc\t60\tIN\tTXT\t\"v=DMARC1; p=none\"",
+				"www\t3600\tIN\tA\t1.2.3.4",
+			}, nil},
+		},
+		{
+			"Absolute names, $ORIGIN, relative targets",
+			normalizeZone,
+			[]interface{}{`$ORIGIN example.com.
+$TTL 1h
+WWW.example.com. CNAME web
+web IN 300 A 1.2.3.4
+`, "example.com."},
+			[]interface{}{[]string{
+				"web\t300\tIN\tA\t1.2.3.4",
+				"www\t3600\tIN\tCNAME\tweb.example.com.",
+			}, nil},
+		},
+		{
+			"Missing owner",
+			normalizeZone,
+			[]interface{}{"  IN A 1.2.3.4\n", "example.com"},
+			[]interface{}{[]string(nil), fmt.Errorf("Line 1: no owner")},
+		},
+	})
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	doTests(t, []test{
+		{
+			"No changes",
+			unifiedDiff,
+			[]interface{}{[]string{"a", "b"}, []string{"a", "b"}, "x", "y", 3},
+			[]interface{}{""},
+		},
+		{
+			"Change in the middle",
+			unifiedDiff,
+			[]interface{}{
+				[]string{"a", "b", "c", "d", "e"},
+				[]string{"a", "b", "C", "d", "e"},
+				"x", "y", 1,
+			},
+			[]interface{}{"--- x\n+++ y\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n"},
+		},
+		{
+			"Two hunks",
+			unifiedDiff,
+			[]interface{}{
+				[]string{"a", "b", "c", "d", "e", "f"},
+				[]string{"b", "c", "d", "e", "f", "g"},
+				"x", "y", 1,
+			},
+			[]interface{}{"--- x\n+++ y\n@@ -1,2 +1 @@\n-a\n b\n@@ -6 +5,2 @@\n f\n+g\n"},
+		},
+		{
+			"From nothing",
+			unifiedDiff,
+			[]interface{}{[]string{}, []string{"a"}, "x", "y", 3},
+			[]interface{}{"--- x\n+++ y\n@@ -0,0 +1 @@\n+a\n"},
+		},
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+// Declarative DNS: compute the changes needed for a zone's
+// records to match a (YAML/TOML) specification.
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// e.g. (YAML)
+//
+//	ttl: 3600
+//	records:
+//	  - { subDomain: "@",   type: A,     target: 1.2.3.4 }
+//	  - { subDomain: www,   type: CNAME, target: example.com., ttl: 60 }
+type ZoneSpec struct {
+	TTL     int              `yaml:"ttl" toml:"ttl"`
+	Records []ZoneSpecRecord `yaml:"records" toml:"records"`
+}
+
+type ZoneSpecRecord struct {
+	SubDomain string `yaml:"subDomain" toml:"subDomain"`
+	Type      string `yaml:"type" toml:"type"`
+	Target    string `yaml:"target" toml:"target"`
+	TTL       int    `yaml:"ttl" toml:"ttl"`
+}
+
+// A single change to a zone:
+//
+//	'+': New is to be created
+//	'~': Old is to be updated to New
+//	'-': Old is to be deleted
+type ZoneChange struct {
+	Op  byte
+	Old GetDomainZoneZoneNameRecordId
+	New GetDomainZoneZoneNameRecordId
+}
+
+func fmtSubDomain(s string) string {
+	if s == "" {
+		return "@"
+	}
+	return s
+}
+
+func (x ZoneChange) String() string {
+	switch x.Op {
+	case '+':
+		return fmt.Sprintf("+ %s\t%d\t%s\t%s",
+			fmtSubDomain(x.New.SubDomain), x.New.TTL, x.New.FieldType, x.New.Target)
+	case '-':
+		return fmt.Sprintf("- %s\t%d\t%s\t%s\t(%d)",
+			fmtSubDomain(x.Old.SubDomain), x.Old.TTL, x.Old.FieldType, x.Old.Target, x.Old.Id)
+	}
+	s := fmt.Sprintf("~ %s\t%d\t%s\t%s\t(%d)",
+		fmtSubDomain(x.Old.SubDomain), x.Old.TTL, x.Old.FieldType, x.Old.Target, x.Old.Id)
+	if x.Old.Target != x.New.Target {
+		s += fmt.Sprintf("\ttarget: %s", x.New.Target)
+	}
+	if x.Old.TTL != x.New.TTL {
+		s += fmt.Sprintf("\tttl: %d", x.New.TTL)
+	}
+	return s
+}
+
+// load a spec from fn; TOML if fn ends with .toml,
+// YAML (and thus JSON) otherwise.
+func loadZoneSpec(fn string) (*ZoneSpec, error) {
+	var x ZoneSpec
+
+	s, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(filepath.Ext(fn)) == ".toml" {
+		if _, err := toml.Decode(string(s), &x); err != nil {
+			return nil, fmt.Errorf("%s: %s", fn, err)
+		}
+	} else {
+		d := yaml.NewDecoder(bytes.NewReader(s))
+		d.KnownFields(true)
+		if err := d.Decode(&x); err != nil {
+			return nil, fmt.Errorf("%s: %s", fn, err)
+		}
+	}
+
+	for i, r := range x.Records {
+		if r.Type == "" || r.Target == "" {
+			return nil, fmt.Errorf("%s: record #%d: missing type or target", fn, i)
+		}
+	}
+
+	return &x, nil
+}
+
+// records described by x, in OVH's format
+func (x *ZoneSpec) records() []GetDomainZoneZoneNameRecordId {
+	ys := make([]GetDomainZoneZoneNameRecordId, 0, len(x.Records))
+	for _, r := range x.Records {
+		y := GetDomainZoneZoneNameRecordId{
+			FieldType: strings.ToUpper(r.Type),
+			SubDomain: strings.ToLower(subDomain(r.SubDomain)),
+			Target:    strings.TrimSpace(r.Target),
+			TTL:       r.TTL,
+		}
+		if y.TTL == 0 {
+			y.TTL = x.TTL
+		}
+		ys = append(ys, y)
+	}
+	return ys
+}
+
+// compute the changes for the live records xs to match the
+// wanted records ys. Live records matching no wanted records
+// are only deleted if prune is set; the apex NS records (the
+// zone's delegation) are kept unless ys has some.
+//
+// Changes are ordered: deletions first, then updates, and
+// finally creations.
+func planZone(xs, ys []GetDomainZoneZoneNameRecordId, prune bool) []ZoneChange {
+	type key struct{ s, t string }
+	k := func(x GetDomainZoneZoneNameRecordId) key {
+		return key{strings.ToLower(x.SubDomain), strings.ToUpper(x.FieldType)}
+	}
+
+	var ds, us, cs []ZoneChange
+
+	xused := make([]bool, len(xs))
+	yused := make([]bool, len(ys))
+
+	// same key, same target: at most a TTL update
+	for j, y := range ys {
+		for i, x := range xs {
+			if xused[i] || k(x) != k(y) || x.Target != y.Target {
+				continue
+			}
+			xused[i], yused[j] = true, true
+			if x.TTL != y.TTL {
+				z := x
+				z.TTL = y.TTL
+				us = append(us, ZoneChange{'~', x, z})
+			}
+			break
+		}
+	}
+
+	// same key, different target: update, or create
+	// if no live record is available.
+	for j, y := range ys {
+		if yused[j] {
+			continue
+		}
+		for i, x := range xs {
+			if xused[i] || k(x) != k(y) {
+				continue
+			}
+			xused[i], yused[j] = true, true
+			z := x
+			z.Target, z.TTL = y.Target, y.TTL
+			us = append(us, ZoneChange{'~', x, z})
+			break
+		}
+		if !yused[j] {
+			yused[j] = true
+			cs = append(cs, ZoneChange{'+', GetDomainZoneZoneNameRecordId{}, y})
+		}
+	}
+
+	if prune {
+		ns := false
+		for _, y := range ys {
+			ns = ns || k(y) == key{"", "NS"}
+		}
+		for i, x := range xs {
+			if !xused[i] && (ns || k(x) != key{"", "NS"}) {
+				ds = append(ds, ZoneChange{'-', x, GetDomainZoneZoneNameRecordId{}})
+			}
+		}
+	}
+
+	return append(append(ds, us...), cs...)
+}
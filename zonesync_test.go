@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+)
+
+type zrec = GetDomainZoneZoneNameRecordId
+
+func TestPlanZone(t *testing.T) {
+	www := zrec{Id: 1, SubDomain: "www", FieldType: "A", Target: "1.2.3.4", TTL: 60}
+	mx := zrec{Id: 2, SubDomain: "", FieldType: "MX", Target: "1 mx.example.com.", TTL: 0}
+	ns1 := zrec{Id: 3, SubDomain: "", FieldType: "NS", Target: "dns1.ovh.net.", TTL: 0}
+	ns2 := zrec{Id: 4, SubDomain: "", FieldType: "NS", Target: "ns1.ovh.net.", TTL: 0}
+
+	doTests(t, []test{
+		{
+			"Nothing to do",
+			planZone,
+			[]interface{}{
+				[]zrec{www, mx},
+				[]zrec{
+					{SubDomain: "www", FieldType: "a", Target: "1.2.3.4", TTL: 60},
+				},
+				false,
+			},
+			[]interface{}{[]ZoneChange(nil)},
+		},
+		{
+			"TTL update",
+			planZone,
+			[]interface{}{
+				[]zrec{www},
+				[]zrec{{SubDomain: "www", FieldType: "A", Target: "1.2.3.4", TTL: 300}},
+				false,
+			},
+			[]interface{}{[]ZoneChange{
+				{'~', www, zrec{Id: 1, SubDomain: "www", FieldType: "A", Target: "1.2.3.4", TTL: 300}},
+			}},
+		},
+		{
+			"Target update and creation",
+			planZone,
+			[]interface{}{
+				[]zrec{www},
+				[]zrec{
+					{SubDomain: "www", FieldType: "A", Target: "5.6.7.8", TTL: 60},
+					{SubDomain: "www", FieldType: "A", Target: "9.9.9.9", TTL: 60},
+				},
+				false,
+			},
+			[]interface{}{[]ZoneChange{
+				{'~', www, zrec{Id: 1, SubDomain: "www", FieldType: "A", Target: "5.6.7.8", TTL: 60}},
+				{'+', zrec{}, zrec{SubDomain: "www", FieldType: "A", Target: "9.9.9.9", TTL: 60}},
+			}},
+		},
+		{
+			"Prune",
+			planZone,
+			[]interface{}{
+				[]zrec{www, mx},
+				[]zrec{www},
+				true,
+			},
+			[]interface{}{[]ZoneChange{
+				{'-', mx, zrec{}},
+			}},
+		},
+		{
+			"Prune keeps the delegation",
+			planZone,
+			[]interface{}{
+				[]zrec{www, ns1, ns2},
+				[]zrec{},
+				true,
+			},
+			[]interface{}{[]ZoneChange{
+				{'-', www, zrec{}},
+			}},
+		},
+		{
+			"Prune delegation from spec",
+			planZone,
+			[]interface{}{
+				[]zrec{ns1, ns2},
+				[]zrec{{SubDomain: "", FieldType: "NS", Target: "dns1.example.net.", TTL: 0}},
+				true,
+			},
+			[]interface{}{[]ZoneChange{
+				{'-', ns2, zrec{}},
+				{'~', ns1, zrec{Id: 3, SubDomain: "", FieldType: "NS", Target: "dns1.example.net.", TTL: 0}},
+			}},
+		},
+	})
+}
+
+func TestZoneChangeString(t *testing.T) {
+	www := zrec{Id: 1, SubDomain: "www", FieldType: "A", Target: "1.2.3.4", TTL: 60}
+	doTests(t, []test{
+		{
+			"creation at apex",
+			ZoneChange.String,
+			[]interface{}{ZoneChange{'+', zrec{}, zrec{FieldType: "A", Target: "1.2.3.4"}}},
+			[]interface{}{"+ @\t0\tA\t1.2.3.4"},
+		},
+		{
+			"update",
+			ZoneChange.String,
+			[]interface{}{ZoneChange{'~', www, zrec{Id: 1, SubDomain: "www", FieldType: "A", Target: "5.6.7.8", TTL: 60}}},
+			[]interface{}{"~ www\t60\tA\t1.2.3.4\t(1)\ttarget: 5.6.7.8"},
+		},
+		{
+			"deletion",
+			ZoneChange.String,
+			[]interface{}{ZoneChange{'-', www, zrec{}}},
+			[]interface{}{"- www\t60\tA\t1.2.3.4\t(1)"},
+		},
+	})
+}